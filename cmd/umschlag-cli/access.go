@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplAccessCheck represents the effective permission within details view.
+var tmplAccessCheck = `User: {{ highlight .User }}
+Org: {{ .Org }}
+Permission: {{ .Perm }}{{ range .Grants }}
+Granted: {{ if eq .Source "direct" }}directly{{ else }}via team {{ .Team }} ({{ .TeamPerm }}){{ end }} as {{ .Perm }}{{ if .Effective }} (effective){{ end }}{{ end }}
+`
+
+// tableAccessCheck defines the columns within the access check.
+var tableAccessCheck = []outputColumn{
+	{Title: "USER", Value: `{{ .User }}`},
+	{Title: "ORG", Value: `{{ .Org }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+	{Title: "GRANTED BY", Value: `{{ range $i, $g := .Grants }}{{ if $i }}, {{ end }}{{ if eq .Source "direct" }}direct{{ else }}team {{ .Team }}{{ end }} ({{ .Perm }}{{ if .Effective }}, effective{{ end }}){{ end }}`},
+}
+
+// tmplAccessMatrix represents a row within the access matrix.
+var tmplAccessMatrix = `User: {{ highlight .User }}{{ range .Orgs }}
+{{ .Org }}: {{ .Perm }}{{ end }}
+`
+
+// accessNone defines the permission of users without any grant on an org.
+const accessNone = "none"
+
+// accessGrant represents a single path granting access to an org.
+type accessGrant struct {
+	Source    string `json:"source" xml:"source"`
+	Team      string `json:"team,omitempty" xml:"team,omitempty"`
+	TeamPerm  string `json:"team_perm,omitempty" xml:"team_perm,omitempty"`
+	Perm      string `json:"perm" xml:"perm"`
+	Effective bool   `json:"effective" xml:"effective"`
+}
+
+// accessCheckRecord represents the effective permission of a user on an org.
+type accessCheckRecord struct {
+	XMLName xml.Name       `json:"-" xml:"access"`
+	User    string         `json:"user" xml:"user"`
+	Org     string         `json:"org" xml:"org"`
+	Perm    string         `json:"perm" xml:"perm"`
+	Grants  []*accessGrant `json:"grants" xml:"grants>grant"`
+}
+
+// accessMatrixEntry represents the effective permission on a single org.
+type accessMatrixEntry struct {
+	Org  string `json:"org" xml:"org"`
+	Perm string `json:"perm" xml:"perm"`
+}
+
+// accessMatrixRecord represents a row of users within the access matrix.
+type accessMatrixRecord struct {
+	XMLName xml.Name             `json:"-" xml:"user"`
+	User    string               `json:"user" xml:"name"`
+	Orgs    []*accessMatrixEntry `json:"orgs" xml:"orgs>org"`
+}
+
+// Perm returns the effective permission on the org, used by the columns.
+func (r *accessMatrixRecord) Perm(org string) string {
+	for _, entry := range r.Orgs {
+		if entry.Org == org {
+			return entry.Perm
+		}
+	}
+
+	return accessNone
+}
+
+// permRank orders the permissions, higher permissions get a higher rank.
+func permRank(perm string) int {
+	for i, val := range permissions {
+		if val == perm {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// resolveAccess collects all direct and team-derived grants, grouped by the
+// username and the org slug.
+func resolveAccess(live *liveState) (map[string]map[string][]*accessGrant, error) {
+	result := map[string]map[string][]*accessGrant{}
+
+	add := func(username, org string, grant *accessGrant) {
+		if _, ok := result[username]; !ok {
+			result[username] = map[string][]*accessGrant{}
+		}
+
+		result[username][org] = append(result[username][org], grant)
+	}
+
+	for _, user := range live.users {
+		for _, member := range live.userOrgs[user.ID] {
+			if member.Org == nil {
+				return nil, fmt.Errorf("org membership of user %s without org", user.Username)
+			}
+
+			add(user.Username, member.Org.Slug, &accessGrant{
+				Source: "direct",
+				Perm:   member.Perm,
+			})
+		}
+	}
+
+	for _, team := range live.teams {
+		for _, member := range live.teamUsers[team.ID] {
+			if member.User == nil {
+				return nil, fmt.Errorf("user membership of team %s without user", team.Slug)
+			}
+
+			for _, org := range live.teamOrgs[team.ID] {
+				if org.Org == nil {
+					return nil, fmt.Errorf("org membership of team %s without org", team.Slug)
+				}
+
+				add(member.User.Username, org.Org.Slug, &accessGrant{
+					Source:   "team",
+					Team:     team.Slug,
+					TeamPerm: member.Perm,
+					Perm:     org.Perm,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// effectiveGrant returns the grant with the highest permission, the grants
+// are not modified as they are shared between the users and orgs.
+func effectiveGrant(grants []*accessGrant) *accessGrant {
+	var best *accessGrant
+
+	for _, grant := range grants {
+		if best == nil || permRank(grant.Perm) > permRank(best.Perm) {
+			best = grant
+		}
+	}
+
+	return best
+}
+
+// effectivePerm returns the highest permission of the grants, without any
+// grant the permission is none.
+func effectivePerm(grants []*accessGrant) string {
+	if best := effectiveGrant(grants); best != nil {
+		return best.Perm
+	}
+
+	return accessNone
+}
+
+// findAccessUser resolves a user by ID, slug or username.
+func findAccessUser(live *liveState, val string) *umschlag.User {
+	for _, user := range live.users {
+		if val == strconv.FormatInt(user.ID, 10) || val == user.Slug || val == user.Username {
+			return user
+		}
+	}
+
+	return nil
+}
+
+// findAccessOrg resolves an org by ID or slug.
+func findAccessOrg(live *liveState, val string) *umschlag.Org {
+	for _, org := range live.orgs {
+		if val == strconv.FormatInt(org.ID, 10) || val == org.Slug {
+			return org
+		}
+	}
+
+	return nil
+}
+
+// Access provides the sub-command to resolve effective permissions.
+func Access() *cli.Command {
+	return &cli.Command{
+		Name:  "access",
+		Usage: "Effective permission related sub-commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "check",
+				Usage:     "Explain the effective permission of a user on an org",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "user",
+						Value: "",
+						Usage: "User ID, slug or username to check",
+					},
+					&cli.StringFlag{
+						Name:  "org",
+						Value: "",
+						Usage: "Org ID or slug to check",
+					},
+				}, outputFlags(tmplAccessCheck)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, AccessCheck)
+				},
+			},
+			{
+				Name:      "matrix",
+				Usage:     "Display the effective permissions of all users on all orgs",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "org",
+						Usage: "Org ID or slug to include, can be repeated, defaults to all",
+					},
+				}, outputFlags(tmplAccessMatrix)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, AccessMatrix)
+				},
+			},
+		},
+	}
+}
+
+// AccessCheck provides the sub-command to explain the effective permission.
+func AccessCheck(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.String("user") == "" || c.String("org") == "" {
+		return fmt.Errorf("you must provide a user and an org")
+	}
+
+	live, err := fetchLiveState(client)
+
+	if err != nil {
+		return err
+	}
+
+	user := findAccessUser(live, c.String("user"))
+
+	if user == nil {
+		return fmt.Errorf("user %s doesn't exist", c.String("user"))
+	}
+
+	org := findAccessOrg(live, c.String("org"))
+
+	if org == nil {
+		return fmt.Errorf("org %s doesn't exist", c.String("org"))
+	}
+
+	access, err := resolveAccess(live)
+
+	if err != nil {
+		return err
+	}
+
+	var (
+		grants = access[user.Username][org.Slug]
+		best   = effectiveGrant(grants)
+	)
+
+	record := &accessCheckRecord{
+		User:   user.Username,
+		Org:    org.Slug,
+		Perm:   effectivePerm(grants),
+		Grants: make([]*accessGrant, 0, len(grants)),
+	}
+
+	for _, grant := range grants {
+		explained := *grant
+		explained.Effective = grant == best
+
+		record.Grants = append(record.Grants, &explained)
+	}
+
+	return RenderRecord(c, record, tableAccessCheck)
+}
+
+// AccessMatrix provides the sub-command to display users by orgs.
+func AccessMatrix(c *cli.Context, client umschlag.ClientAPI) error {
+	live, err := fetchLiveState(client)
+
+	if err != nil {
+		return err
+	}
+
+	orgs := []string{}
+
+	if vals := c.StringSlice("org"); len(vals) > 0 {
+		for _, val := range vals {
+			org := findAccessOrg(live, val)
+
+			if org == nil {
+				return fmt.Errorf("org %s doesn't exist", val)
+			}
+
+			orgs = append(orgs, org.Slug)
+		}
+	} else {
+		for _, org := range live.orgs {
+			orgs = append(orgs, org.Slug)
+		}
+
+		sort.Strings(orgs)
+	}
+
+	access, err := resolveAccess(live)
+
+	if err != nil {
+		return err
+	}
+
+	var (
+		records = []*accessMatrixRecord{}
+		columns = []outputColumn{
+			{Title: "USER", Value: `{{ .User }}`},
+		}
+	)
+
+	for _, org := range orgs {
+		columns = append(columns, outputColumn{
+			Title: org,
+			Value: fmt.Sprintf(`{{ .Perm %q }}`, org),
+		})
+	}
+
+	for _, user := range live.users {
+		record := &accessMatrixRecord{
+			User: user.Username,
+			Orgs: []*accessMatrixEntry{},
+		}
+
+		for _, org := range orgs {
+			record.Orgs = append(record.Orgs, &accessMatrixEntry{
+				Org:  org,
+				Perm: effectivePerm(access[user.Username][org]),
+			})
+		}
+
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].User < records[j].User
+	})
+
+	return RenderList(c, records, columns)
+}
@@ -0,0 +1,1016 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// State represents the desired orgs, teams, users and their memberships.
+type State struct {
+	Orgs  []*StateOrg  `yaml:"orgs,omitempty" json:"orgs,omitempty"`
+	Teams []*StateTeam `yaml:"teams,omitempty" json:"teams,omitempty"`
+	Users []*StateUser `yaml:"users,omitempty" json:"users,omitempty"`
+}
+
+// StateOrg represents a desired org, identified by the slug.
+type StateOrg struct {
+	Slug     string `yaml:"slug" json:"slug"`
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+	Registry string `yaml:"registry" json:"registry"`
+}
+
+// StateTeam represents a desired team, identified by the slug. The users are
+// mapped by username and the orgs by slug to the permission.
+type StateTeam struct {
+	Slug  string            `yaml:"slug" json:"slug"`
+	Name  string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Users map[string]string `yaml:"users,omitempty" json:"users,omitempty"`
+	Orgs  map[string]string `yaml:"orgs,omitempty" json:"orgs,omitempty"`
+}
+
+// StateUser represents a desired user, identified by the username. The orgs
+// are mapped by slug to the permission, the password is only used to create
+// the user and supports environment variables.
+type StateUser struct {
+	Username string            `yaml:"username" json:"username"`
+	Slug     string            `yaml:"slug,omitempty" json:"slug,omitempty"`
+	Email    string            `yaml:"email" json:"email"`
+	Password string            `yaml:"password,omitempty" json:"password,omitempty"`
+	Active   *bool             `yaml:"active,omitempty" json:"active,omitempty"`
+	Admin    *bool             `yaml:"admin,omitempty" json:"admin,omitempty"`
+	Orgs     map[string]string `yaml:"orgs,omitempty" json:"orgs,omitempty"`
+}
+
+// LoadState reads the state file, use - to read from stdin.
+func LoadState(path string) (*State, error) {
+	var (
+		content []byte
+		err     error
+	)
+
+	if path == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{}
+
+	if err := yaml.UnmarshalStrict(content, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	if err := state.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", path, err)
+	}
+
+	return state, nil
+}
+
+// Validate checks for required fields, duplicates and valid permissions.
+func (s *State) Validate() error {
+	orgs := map[string]bool{}
+
+	for _, org := range s.Orgs {
+		if org.Slug == "" {
+			return fmt.Errorf("org without slug")
+		}
+
+		if orgs[org.Slug] {
+			return fmt.Errorf("org %s defined twice", org.Slug)
+		}
+
+		if org.Registry == "" {
+			return fmt.Errorf("org %s requires a registry", org.Slug)
+		}
+
+		orgs[org.Slug] = true
+	}
+
+	teams := map[string]bool{}
+
+	for _, team := range s.Teams {
+		if team.Slug == "" {
+			return fmt.Errorf("team without slug")
+		}
+
+		if teams[team.Slug] {
+			return fmt.Errorf("team %s defined twice", team.Slug)
+		}
+
+		teams[team.Slug] = true
+
+		if err := validatePerms("team "+team.Slug, team.Users); err != nil {
+			return err
+		}
+
+		if err := validatePerms("team "+team.Slug, team.Orgs); err != nil {
+			return err
+		}
+	}
+
+	users := map[string]bool{}
+
+	for _, user := range s.Users {
+		if user.Username == "" {
+			return fmt.Errorf("user without username")
+		}
+
+		if users[user.Username] {
+			return fmt.Errorf("user %s defined twice", user.Username)
+		}
+
+		users[user.Username] = true
+
+		if err := validatePerms("user "+user.Username, user.Orgs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePerms checks the permissions of a membership mapping.
+func validatePerms(owner string, members map[string]string) error {
+	for name, perm := range members {
+		if !validPerm(perm) {
+			return fmt.Errorf("%s has invalid permission %q for %s, can be user, admin or owner", owner, perm, name)
+		}
+	}
+
+	return nil
+}
+
+// liveState represents the current records on the server.
+type liveState struct {
+	registries []*umschlag.Registry
+	orgs       []*umschlag.Org
+	teams      []*umschlag.Team
+	users      []*umschlag.User
+	teamUsers  map[int64][]*umschlag.TeamUser
+	teamOrgs   map[int64][]*umschlag.TeamOrg
+	userOrgs   map[int64][]*umschlag.UserOrg
+}
+
+// fetchLiveState loads all records including the memberships of all teams
+// and users from the server.
+func fetchLiveState(client umschlag.ClientAPI) (*liveState, error) {
+	var (
+		live = &liveState{
+			teamUsers: map[int64][]*umschlag.TeamUser{},
+			teamOrgs:  map[int64][]*umschlag.TeamOrg{},
+			userOrgs:  map[int64][]*umschlag.UserOrg{},
+		}
+		err error
+	)
+
+	if live.registries, err = client.RegistryList(); err != nil {
+		return nil, err
+	}
+
+	if live.orgs, err = client.OrgList(); err != nil {
+		return nil, err
+	}
+
+	if live.teams, err = client.TeamList(); err != nil {
+		return nil, err
+	}
+
+	if live.users, err = client.UserList(); err != nil {
+		return nil, err
+	}
+
+	for _, team := range live.teams {
+		id := strconv.FormatInt(team.ID, 10)
+
+		if live.teamUsers[team.ID], err = client.TeamUserList(umschlag.TeamUserParams{Team: id}); err != nil {
+			return nil, err
+		}
+
+		if live.teamOrgs[team.ID], err = client.TeamOrgList(umschlag.TeamOrgParams{Team: id}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, user := range live.users {
+		id := strconv.FormatInt(user.ID, 10)
+
+		if live.userOrgs[user.ID], err = client.UserOrgList(umschlag.UserOrgParams{User: id}); err != nil {
+			return nil, err
+		}
+	}
+
+	return live, nil
+}
+
+// registrySlug returns the slug of the registry with the given ID.
+func (l *liveState) registrySlug(id int64) string {
+	for _, registry := range l.registries {
+		if registry.ID == id {
+			return registry.Slug
+		}
+	}
+
+	return strconv.FormatInt(id, 10)
+}
+
+// registryID resolves a registry slug or ID.
+func (l *liveState) registryID(val string) (int64, bool) {
+	for _, registry := range l.registries {
+		if registry.Slug == val || strconv.FormatInt(registry.ID, 10) == val {
+			return registry.ID, true
+		}
+	}
+
+	return 0, false
+}
+
+// planField represents a changed attribute within the plan.
+type planField struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// planChange represents a single change within the plan.
+type planChange struct {
+	Action string
+	Kind   string
+	Name   string
+	Fields []planField
+	run    func() error
+}
+
+// Plan represents the ordered changes to reach the desired state.
+type Plan struct {
+	Changes []*planChange
+}
+
+// planner computes the plan, the references map slugs and usernames to IDs
+// and gets extended while creating records during the apply.
+type planner struct {
+	client umschlag.ClientAPI
+	state  *State
+	live   *liveState
+	prune  bool
+	refs   map[string]string
+	plan   *Plan
+}
+
+// PlanState compares the desired state with the server, memberships missing
+// within declared records and unmanaged records are only deleted if prune is
+// enabled. The current user is never deleted.
+func PlanState(client umschlag.ClientAPI, state *State, live *liveState, prune bool) (*Plan, error) {
+	p := &planner{
+		client: client,
+		state:  state,
+		live:   live,
+		prune:  prune,
+		refs:   map[string]string{},
+		plan:   &Plan{},
+	}
+
+	for _, org := range live.orgs {
+		p.refs["org:"+org.Slug] = strconv.FormatInt(org.ID, 10)
+	}
+
+	for _, team := range live.teams {
+		p.refs["team:"+team.Slug] = strconv.FormatInt(team.ID, 10)
+	}
+
+	for _, user := range live.users {
+		p.refs["user:"+user.Username] = strconv.FormatInt(user.ID, 10)
+	}
+
+	for _, org := range state.Orgs {
+		p.declare("org", org.Slug)
+	}
+
+	for _, team := range state.Teams {
+		p.declare("team", team.Slug)
+	}
+
+	for _, user := range state.Users {
+		p.declare("user", user.Username)
+	}
+
+	if err := p.planOrgs(); err != nil {
+		return nil, err
+	}
+
+	p.planTeams()
+
+	if err := p.planUsers(); err != nil {
+		return nil, err
+	}
+
+	if err := p.planMemberships(); err != nil {
+		return nil, err
+	}
+
+	if prune {
+		if err := p.planPrune(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.plan, nil
+}
+
+// add appends a change to the plan.
+func (p *planner) add(change *planChange) {
+	p.plan.Changes = append(p.plan.Changes, change)
+}
+
+// declare marks a record of the state as known, records which don't exist
+// yet get their ID once they have been created.
+func (p *planner) declare(kind, name string) {
+	if _, ok := p.refs[kind+":"+name]; !ok {
+		p.refs[kind+":"+name] = ""
+	}
+}
+
+// ref returns the ID of a record, it has to be resolved at apply time as
+// records created by the plan don't have an ID before.
+func (p *planner) ref(kind, name string) string {
+	if val := p.refs[kind+":"+name]; val != "" {
+		return val
+	}
+
+	return name
+}
+
+// known checks if the record exists or gets created by the plan.
+func (p *planner) known(kind, name string) bool {
+	_, ok := p.refs[kind+":"+name]
+	return ok
+}
+
+// planOrgs creates or updates the declared orgs.
+func (p *planner) planOrgs() error {
+	current := map[string]*umschlag.Org{}
+
+	for _, org := range p.live.orgs {
+		current[org.Slug] = org
+	}
+
+	for _, desired := range p.state.Orgs {
+		desired := desired
+		registry, ok := p.live.registryID(desired.Registry)
+
+		if !ok {
+			return fmt.Errorf("unknown registry %s for org %s", desired.Registry, desired.Slug)
+		}
+
+		name := desired.Name
+
+		if name == "" {
+			name = desired.Slug
+		}
+
+		record, ok := current[desired.Slug]
+
+		if !ok {
+			p.add(&planChange{
+				Action: "create",
+				Kind:   "org",
+				Name:   desired.Slug,
+				Fields: []planField{
+					{Name: "name", New: name},
+					{Name: "registry", New: desired.Registry},
+				},
+				run: func() error {
+					created, err := p.client.OrgPost(&umschlag.Org{
+						Slug:       desired.Slug,
+						Name:       name,
+						RegistryID: registry,
+					})
+
+					if err != nil {
+						return err
+					}
+
+					p.refs["org:"+desired.Slug] = strconv.FormatInt(created.ID, 10)
+					return nil
+				},
+			})
+
+			continue
+		}
+
+		fields := []planField{}
+
+		if record.Name != name {
+			fields = append(fields, planField{Name: "name", Old: record.Name, New: name})
+		}
+
+		if record.RegistryID != registry {
+			fields = append(fields, planField{Name: "registry", Old: p.live.registrySlug(record.RegistryID), New: desired.Registry})
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "update",
+			Kind:   "org",
+			Name:   desired.Slug,
+			Fields: fields,
+			run: func() error {
+				record.Name = name
+				record.RegistryID = registry
+
+				_, err := p.client.OrgPatch(record)
+				return err
+			},
+		})
+	}
+
+	return nil
+}
+
+// planTeams creates or updates the declared teams.
+func (p *planner) planTeams() {
+	current := map[string]*umschlag.Team{}
+
+	for _, team := range p.live.teams {
+		current[team.Slug] = team
+	}
+
+	for _, desired := range p.state.Teams {
+		desired := desired
+		name := desired.Name
+
+		if name == "" {
+			name = desired.Slug
+		}
+
+		record, ok := current[desired.Slug]
+
+		if !ok {
+			p.add(&planChange{
+				Action: "create",
+				Kind:   "team",
+				Name:   desired.Slug,
+				Fields: []planField{
+					{Name: "name", New: name},
+				},
+				run: func() error {
+					created, err := p.client.TeamPost(&umschlag.Team{
+						Slug: desired.Slug,
+						Name: name,
+					})
+
+					if err != nil {
+						return err
+					}
+
+					p.refs["team:"+desired.Slug] = strconv.FormatInt(created.ID, 10)
+					return nil
+				},
+			})
+
+			continue
+		}
+
+		if record.Name == name {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "update",
+			Kind:   "team",
+			Name:   desired.Slug,
+			Fields: []planField{
+				{Name: "name", Old: record.Name, New: name},
+			},
+			run: func() error {
+				record.Name = name
+
+				_, err := p.client.TeamPatch(record)
+				return err
+			},
+		})
+	}
+}
+
+// planUsers creates or updates the declared users, passwords are only set
+// for new users.
+func (p *planner) planUsers() error {
+	current := map[string]*umschlag.User{}
+
+	for _, user := range p.live.users {
+		current[user.Username] = user
+	}
+
+	for _, desired := range p.state.Users {
+		desired := desired
+		record, ok := current[desired.Username]
+
+		if !ok {
+			password := os.ExpandEnv(desired.Password)
+
+			if password == "" {
+				return fmt.Errorf("user %s requires a password to be created", desired.Username)
+			}
+
+			user := &umschlag.User{
+				Slug:     desired.Slug,
+				Username: desired.Username,
+				Email:    desired.Email,
+				Password: password,
+				Active:   desired.Active == nil || *desired.Active,
+				Admin:    desired.Admin != nil && *desired.Admin,
+			}
+
+			p.add(&planChange{
+				Action: "create",
+				Kind:   "user",
+				Name:   desired.Username,
+				Fields: []planField{
+					{Name: "email", New: user.Email},
+					{Name: "active", New: strconv.FormatBool(user.Active)},
+					{Name: "admin", New: strconv.FormatBool(user.Admin)},
+				},
+				run: func() error {
+					created, err := p.client.UserPost(user)
+
+					if err != nil {
+						return err
+					}
+
+					p.refs["user:"+desired.Username] = strconv.FormatInt(created.ID, 10)
+					return nil
+				},
+			})
+
+			continue
+		}
+
+		fields := []planField{}
+		update := *record
+
+		if desired.Email != "" && record.Email != desired.Email {
+			fields = append(fields, planField{Name: "email", Old: record.Email, New: desired.Email})
+			update.Email = desired.Email
+		}
+
+		if desired.Active != nil && record.Active != *desired.Active {
+			fields = append(fields, planField{Name: "active", Old: strconv.FormatBool(record.Active), New: strconv.FormatBool(*desired.Active)})
+			update.Active = *desired.Active
+		}
+
+		if desired.Admin != nil && record.Admin != *desired.Admin {
+			fields = append(fields, planField{Name: "admin", Old: strconv.FormatBool(record.Admin), New: strconv.FormatBool(*desired.Admin)})
+			update.Admin = *desired.Admin
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "update",
+			Kind:   "user",
+			Name:   desired.Username,
+			Fields: fields,
+			run: func() error {
+				_, err := p.client.UserPatch(&update)
+				return err
+			},
+		})
+	}
+
+	return nil
+}
+
+// planMemberships adds and updates the memberships of declared teams and
+// users, memberships missing within the state are only removed with prune.
+func (p *planner) planMemberships() error {
+	teams := map[string]*umschlag.Team{}
+
+	for _, team := range p.live.teams {
+		teams[team.Slug] = team
+	}
+
+	users := map[string]*umschlag.User{}
+
+	for _, user := range p.live.users {
+		users[user.Username] = user
+	}
+
+	for _, desired := range p.state.Users {
+		if desired.Orgs == nil {
+			continue
+		}
+
+		current := map[string]string{}
+
+		if record, ok := users[desired.Username]; ok {
+			for _, member := range p.live.userOrgs[record.ID] {
+				if member.Org == nil {
+					return fmt.Errorf("org membership of user %s without org", desired.Username)
+				}
+
+				current[member.Org.Slug] = member.Perm
+			}
+		}
+
+		if err := p.planMembers("user-org", "user", desired.Username, "org", current, desired.Orgs, p.userOrg); err != nil {
+			return err
+		}
+	}
+
+	for _, desired := range p.state.Teams {
+		record, exists := teams[desired.Slug]
+
+		if desired.Users != nil {
+			current := map[string]string{}
+
+			if exists {
+				for _, member := range p.live.teamUsers[record.ID] {
+					if member.User == nil {
+						return fmt.Errorf("user membership of team %s without user", desired.Slug)
+					}
+
+					current[member.User.Username] = member.Perm
+				}
+			}
+
+			if err := p.planMembers("team-user", "team", desired.Slug, "user", current, desired.Users, p.teamUser); err != nil {
+				return err
+			}
+		}
+
+		if desired.Orgs != nil {
+			current := map[string]string{}
+
+			if exists {
+				for _, member := range p.live.teamOrgs[record.ID] {
+					if member.Org == nil {
+						return fmt.Errorf("org membership of team %s without org", desired.Slug)
+					}
+
+					current[member.Org.Slug] = member.Perm
+				}
+			}
+
+			if err := p.planMembers("team-org", "team", desired.Slug, "org", current, desired.Orgs, p.teamOrg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// membershipFunc executes a membership change for the owner and member.
+type membershipFunc func(action, owner, member, perm string) error
+
+// planMembers compares the current with the desired memberships of a record.
+func (p *planner) planMembers(kind, ownerKind, owner, memberKind string, current, desired map[string]string, fn membershipFunc) error {
+	for _, member := range sortedKeys(desired) {
+		member, perm := member, desired[member]
+
+		if !p.known(memberKind, member) {
+			return fmt.Errorf("unknown %s %s referenced by %s %s", memberKind, member, ownerKind, owner)
+		}
+
+		change := &planChange{
+			Kind: kind,
+			Name: owner + "/" + member,
+			run: func() error {
+				return fn(
+					"append",
+					p.ref(ownerKind, owner),
+					p.ref(memberKind, member),
+					perm,
+				)
+			},
+		}
+
+		switch old, ok := current[member]; {
+		case !ok:
+			change.Action = "create"
+			change.Fields = []planField{{Name: "perm", New: perm}}
+		case old != perm:
+			change.Action = "update"
+			change.Fields = []planField{{Name: "perm", Old: old, New: perm}}
+			change.run = func() error {
+				return fn("perm", p.ref(ownerKind, owner), p.ref(memberKind, member), perm)
+			}
+		default:
+			continue
+		}
+
+		p.add(change)
+	}
+
+	if !p.prune {
+		return nil
+	}
+
+	for _, member := range sortedKeys(current) {
+		member := member
+
+		if _, ok := desired[member]; ok {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "delete",
+			Kind:   kind,
+			Name:   owner + "/" + member,
+			Fields: []planField{{Name: "perm", Old: current[member]}},
+			run: func() error {
+				return fn("delete", p.ref(ownerKind, owner), p.ref(memberKind, member), "")
+			},
+		})
+	}
+
+	return nil
+}
+
+// userOrg changes the membership of a user within an org.
+func (p *planner) userOrg(action, user, org, perm string) error {
+	params := umschlag.UserOrgParams{User: user, Org: org, Perm: perm}
+
+	switch action {
+	case "append":
+		return p.client.UserOrgAppend(params)
+	case "perm":
+		return p.client.UserOrgPerm(params)
+	}
+
+	return p.client.UserOrgDelete(params)
+}
+
+// teamUser changes the membership of a user within a team.
+func (p *planner) teamUser(action, team, user, perm string) error {
+	params := umschlag.TeamUserParams{Team: team, User: user, Perm: perm}
+
+	switch action {
+	case "append":
+		return p.client.TeamUserAppend(params)
+	case "perm":
+		return p.client.TeamUserPerm(params)
+	}
+
+	return p.client.TeamUserDelete(params)
+}
+
+// teamOrg changes the membership of a team within an org.
+func (p *planner) teamOrg(action, team, org, perm string) error {
+	params := umschlag.TeamOrgParams{Team: team, Org: org, Perm: perm}
+
+	switch action {
+	case "append":
+		return p.client.TeamOrgAppend(params)
+	case "perm":
+		return p.client.TeamOrgPerm(params)
+	}
+
+	return p.client.TeamOrgDelete(params)
+}
+
+// planPrune deletes all users, teams and orgs which are not declared, in
+// that order to remove dependent records first.
+func (p *planner) planPrune() error {
+	profile, err := p.client.ProfileGet()
+
+	if err != nil {
+		return err
+	}
+
+	users := map[string]bool{}
+
+	for _, user := range p.state.Users {
+		users[user.Username] = true
+	}
+
+	for _, record := range p.live.users {
+		id := strconv.FormatInt(record.ID, 10)
+
+		if users[record.Username] || record.Username == profile.Username {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "delete",
+			Kind:   "user",
+			Name:   record.Username,
+			run: func() error {
+				return p.client.UserDelete(id)
+			},
+		})
+	}
+
+	teams := map[string]bool{}
+
+	for _, team := range p.state.Teams {
+		teams[team.Slug] = true
+	}
+
+	for _, record := range p.live.teams {
+		id := strconv.FormatInt(record.ID, 10)
+
+		if teams[record.Slug] {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "delete",
+			Kind:   "team",
+			Name:   record.Slug,
+			run: func() error {
+				return p.client.TeamDelete(id)
+			},
+		})
+	}
+
+	orgs := map[string]bool{}
+
+	for _, org := range p.state.Orgs {
+		orgs[org.Slug] = true
+	}
+
+	for _, record := range p.live.orgs {
+		id := strconv.FormatInt(record.ID, 10)
+
+		if orgs[record.Slug] {
+			continue
+		}
+
+		p.add(&planChange{
+			Action: "delete",
+			Kind:   "org",
+			Name:   record.Slug,
+			run: func() error {
+				return p.client.OrgDelete(id)
+			},
+		})
+	}
+
+	return nil
+}
+
+// Counts returns the number of creates, updates and deletes.
+func (p *Plan) Counts() (int, int, int) {
+	var create, update, remove int
+
+	for _, change := range p.Changes {
+		switch change.Action {
+		case "create":
+			create++
+		case "update":
+			update++
+		case "delete":
+			remove++
+		}
+	}
+
+	return create, update, remove
+}
+
+// Print writes the plan as a diff.
+func (p *Plan) Print(w io.Writer) {
+	for _, change := range p.Changes {
+		switch change.Action {
+		case "create":
+			fmt.Fprintf(w, "%s %s %s\n", success("+"), change.Kind, highlight(change.Name))
+
+			for _, field := range change.Fields {
+				fmt.Fprintf(w, "    %s: %q\n", field.Name, field.New)
+			}
+		case "update":
+			fmt.Fprintf(w, "%s %s %s\n", warning("~"), change.Kind, highlight(change.Name))
+
+			for _, field := range change.Fields {
+				fmt.Fprintf(w, "    %s: %q => %q\n", field.Name, field.Old, field.New)
+			}
+		case "delete":
+			fmt.Fprintf(w, "%s %s %s\n", danger("-"), change.Kind, highlight(change.Name))
+		}
+	}
+
+	create, update, remove := p.Counts()
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete.\n", create, update, remove)
+}
+
+// Apply executes the changes in order and stops at the first error.
+func (p *Plan) Apply() (int, error) {
+	for i, change := range p.Changes {
+		if err := change.run(); err != nil {
+			return i, fmt.Errorf("failed to %s %s %s: %s", change.Action, change.Kind, change.Name, err)
+		}
+	}
+
+	return len(p.Changes), nil
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(val map[string]string) []string {
+	res := make([]string, 0, len(val))
+
+	for key := range val {
+		res = append(res, key)
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+// applyPlan prints the plan and executes it unless it's a dry run.
+func applyPlan(c *cli.Context, plan *Plan) error {
+	if len(plan.Changes) == 0 {
+		fmt.Fprintf(os.Stderr, "No changes, the server matches the state\n")
+		return nil
+	}
+
+	plan.Print(os.Stdout)
+
+	if c.Bool("dry-run") {
+		fmt.Fprintf(os.Stderr, "Dry run, nothing has been changed\n")
+		return nil
+	}
+
+	applied, err := plan.Apply()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Applied %d of %d changes\n", applied, len(plan.Changes))
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully applied %d changes\n", applied)
+	return nil
+}
+
+// Apply provides the sub-command to apply a declarative state.
+func Apply() *cli.Command {
+	return &cli.Command{
+		Name:      "apply",
+		Usage:     "Apply orgs, teams, users and memberships from a state file",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Value:   "",
+				Usage:   "Path to the state file, use - for stdin",
+			},
+			&cli.BoolFlag{
+				Name:  "prune",
+				Value: false,
+				Usage: "Delete orgs, teams, users and memberships which are not part of the state",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Value: false,
+				Usage: "Only print the plan without applying it",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return Handle(c, ApplyAction)
+		},
+	}
+}
+
+// ApplyAction computes the plan for the state file and applies it.
+func ApplyAction(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.String("file") == "" {
+		return fmt.Errorf("you must provide a state file")
+	}
+
+	state, err := LoadState(c.String("file"))
+
+	if err != nil {
+		return err
+	}
+
+	live, err := fetchLiveState(client)
+
+	if err != nil {
+		return err
+	}
+
+	plan, err := PlanState(client, state, live, c.Bool("prune"))
+
+	if err != nil {
+		return err
+	}
+
+	return applyPlan(c, plan)
+}
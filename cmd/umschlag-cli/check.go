@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/umschlag/umschlag-cli/pkg/distribution"
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplRegistryCheck represents a row within the registry checks.
+var tmplRegistryCheck = `Registry: {{ highlight .Registry }}
+Host: {{ .Host }}
+Status: {{ if eq .Status "ok" }}{{ success .Status }}{{ else if eq .Status "warning" }}{{ warning .Status }}{{ else }}{{ danger .Status }}{{ end }}
+Latency: {{ .LatencyMS }}ms{{ with .Auth }}
+Auth: {{ . }}{{ end }}{{ with .Expires }}
+Expires: {{ .Format "Mon Jan _2 15:04:05 MST 2006" }}{{ end }}{{ range .Chain }}
+Certificate: {{ .Subject }} (issuer {{ .Issuer }}, expires {{ .Expires.Format "2006-01-02" }}){{ end }}{{ with .Error }}
+Error: {{ danger . }}{{ end }}
+`
+
+// tableRegistryCheck defines the columns within the registry checks.
+var tableRegistryCheck = []outputColumn{
+	{Title: "REGISTRY", Value: `{{ .Registry }}`},
+	{Title: "HOST", Value: `{{ .Host }}`},
+	{Title: "STATUS", Value: `{{ .Status }}`},
+	{Title: "LATENCY", Value: `{{ .LatencyMS }}ms`},
+	{Title: "AUTH", Value: `{{ .Auth }}`},
+	{Title: "EXPIRES", Value: `{{ with .Expires }}{{ .Format "2006-01-02" }}{{ end }}`},
+	{Title: "ERROR", Value: `{{ .Error }}`, Wide: true},
+}
+
+// registryCheckCert represents a certificate within the checked chain.
+type registryCheckCert struct {
+	Subject string    `json:"subject" xml:"subject"`
+	Issuer  string    `json:"issuer" xml:"issuer"`
+	Expires time.Time `json:"expires" xml:"expires"`
+}
+
+// registryCheckRecord represents the result of a single registry check.
+type registryCheckRecord struct {
+	XMLName   xml.Name             `json:"-" xml:"check"`
+	Registry  string               `json:"registry" xml:"registry"`
+	Host      string               `json:"host" xml:"host"`
+	Status    string               `json:"status" xml:"status"`
+	LatencyMS int64                `json:"latency_ms" xml:"latency_ms"`
+	Auth      string               `json:"auth,omitempty" xml:"auth,omitempty"`
+	Expires   *time.Time           `json:"expires,omitempty" xml:"expires,omitempty"`
+	Chain     []*registryCheckCert `json:"chain,omitempty" xml:"chain>certificate,omitempty"`
+	Error     string               `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// checkRegistry contacts the API version check endpoint of the registry. The
+// check fails if the registry is unreachable, answers unexpectedly or if the
+// certificate expired, it warns if the certificate expires within warn.
+func checkRegistry(record *umschlag.Registry, timeout, warn time.Duration) *registryCheckRecord {
+	result := &registryCheckRecord{
+		Registry: record.Slug,
+		Host:     record.Host,
+		Status:   "ok",
+	}
+
+	client := distribution.NewClient(record.Host, "", "")
+	client.HTTPClient.Timeout = timeout
+
+	ping, err := client.Ping()
+
+	if ping != nil {
+		result.LatencyMS = ping.Latency.Nanoseconds() / int64(time.Millisecond)
+
+		for _, cert := range ping.Certificates {
+			result.Chain = append(result.Chain, &registryCheckCert{
+				Subject: cert.Subject.CommonName,
+				Issuer:  cert.Issuer.CommonName,
+				Expires: cert.NotAfter,
+			})
+
+			if result.Expires == nil || cert.NotAfter.Before(*result.Expires) {
+				expires := cert.NotAfter
+				result.Expires = &expires
+			}
+		}
+	}
+
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+
+		return result
+	}
+
+	switch ping.AuthScheme {
+	case "":
+		result.Auth = "none"
+	case "bearer":
+		result.Auth = strings.TrimSpace("bearer " + ping.AuthRealm)
+	default:
+		result.Auth = ping.AuthScheme
+	}
+
+	if ping.StatusCode != http.StatusOK && ping.StatusCode != http.StatusUnauthorized {
+		result.Status = "failed"
+		result.Error = fmt.Sprintf("unexpected status %d, not a docker registry", ping.StatusCode)
+
+		return result
+	}
+
+	if result.Expires != nil {
+		now := time.Now()
+
+		switch {
+		case result.Expires.Before(now):
+			result.Status = "failed"
+			result.Error = "certificate expired"
+		case result.Expires.Before(now.Add(warn)):
+			result.Status = "warning"
+			result.Error = fmt.Sprintf("certificate expires within %s", formatAge(warn))
+		}
+	}
+
+	return result
+}
+
+// validateRegistryHost runs the registry check for create and update.
+func validateRegistryHost(c *cli.Context, record *umschlag.Registry) error {
+	if c.Bool("skip-check") {
+		return nil
+	}
+
+	result := checkRegistry(record, 10*time.Second, 0)
+
+	if result.Status == "failed" {
+		return fmt.Errorf("registry check for %s failed: %s, use --skip-check to ignore", record.Host, result.Error)
+	}
+
+	return nil
+}
+
+// RegistryCheck provides the sub-command to check the registry connectivity.
+func RegistryCheck(c *cli.Context, client umschlag.ClientAPI) error {
+	warn, err := ParseAge(c.String("expiry-warning"))
+
+	if err != nil {
+		return err
+	}
+
+	var registries []*umschlag.Registry
+
+	if c.Bool("all") {
+		if c.String("id") != "" {
+			return fmt.Errorf("you can't combine --id with --all")
+		}
+
+		registries, err = client.RegistryList()
+
+		if err != nil {
+			return err
+		}
+	} else {
+		record, err := client.RegistryGet(
+			GetIdentifierParam(c),
+		)
+
+		if err != nil {
+			return err
+		}
+
+		registries = append(registries, record)
+	}
+
+	records := make([]*registryCheckRecord, len(registries))
+
+	for i, registry := range registries {
+		records[i] = checkRegistry(registry, c.Duration("timeout"), warn)
+	}
+
+	if err := RenderList(c, records, tableRegistryCheck); err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, record := range records {
+		if record.Status == "failed" {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d registry checks failed", failed, len(records))
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// colorModes defines the values supported by the color flag.
+var colorModes = []string{
+	"auto",
+	"always",
+	"never",
+}
+
+// colorEnabled defines if the template helpers print ANSI escapes.
+var colorEnabled = false
+
+// SetupColor resolves if colors are enabled, the color flag wins over the
+// NO_COLOR environment variable, which wins over the config file. Otherwise
+// colors are only enabled if stdout is a terminal.
+func SetupColor(c *cli.Context, cfg *Config) error {
+	mode := "auto"
+
+	switch {
+	case c.IsSet("color"):
+		mode = c.String("color")
+	case os.Getenv("NO_COLOR") != "":
+		mode = "never"
+	case cfg != nil && cfg.Color != "":
+		mode = cfg.Color
+	}
+
+	switch mode {
+	case "always":
+		colorEnabled = true
+	case "never":
+		colorEnabled = false
+	case "auto":
+		colorEnabled = terminal.IsTerminal(int(os.Stdout.Fd()))
+	default:
+		return fmt.Errorf("invalid color mode %s, can be auto, always or never", mode)
+	}
+
+	return nil
+}
+
+// colorize wraps the value with the ANSI escape if colors are enabled.
+func colorize(code string, val interface{}) string {
+	if !colorEnabled {
+		return fmt.Sprint(val)
+	}
+
+	return fmt.Sprintf("\x1b[%sm%v\x1b[0m", code, val)
+}
+
+// highlight marks the value as the most important one of a record.
+func highlight(val interface{}) string {
+	return colorize("33", val)
+}
+
+// success marks the value as positive.
+func success(val interface{}) string {
+	return colorize("32", val)
+}
+
+// warning marks the value as something to look at.
+func warning(val interface{}) string {
+	return colorize("35", val)
+}
+
+// danger marks the value as negative.
+func danger(val interface{}) string {
+	return colorize("31", val)
+}
+
+// muted marks the value as less important.
+func muted(val interface{}) string {
+	return colorize("90", val)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// Config represents the persisted configuration file.
+type Config struct {
+	Current  string           `yaml:"current,omitempty"`
+	Color    string           `yaml:"color,omitempty"`
+	Contexts []*ConfigContext `yaml:"contexts,omitempty"`
+
+	path string
+}
+
+// ConfigContext represents a named server context.
+type ConfigContext struct {
+	Name   string `yaml:"name"`
+	Server string `yaml:"server"`
+	Token  string `yaml:"token,omitempty"`
+	Output string `yaml:"output,omitempty"`
+}
+
+// Context returns the context with the given name.
+func (cfg *Config) Context(name string) *ConfigContext {
+	for _, ctx := range cfg.Contexts {
+		if ctx.Name == name {
+			return ctx
+		}
+	}
+
+	return nil
+}
+
+// Remove drops the context with the given name.
+func (cfg *Config) Remove(name string) bool {
+	for i, ctx := range cfg.Contexts {
+		if ctx.Name == name {
+			cfg.Contexts = append(cfg.Contexts[:i], cfg.Contexts[i+1:]...)
+
+			if cfg.Current == name {
+				cfg.Current = ""
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Save writes the config back to the file it was loaded from.
+func (cfg *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(cfg.path), 0700); err != nil {
+		return err
+	}
+
+	res, err := yaml.Marshal(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cfg.path, res, 0600)
+}
+
+// defaultConfigPath returns the location of the config file if not defined,
+// it honors XDG_CONFIG_HOME and falls back to the config dir within home.
+func defaultConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "umschlag", "config.yml")
+	}
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ".umschlag.yml"
+	}
+
+	return filepath.Join(home, ".config", "umschlag", "config.yml")
+}
+
+// configPath returns the location of the config file for the current run.
+func configPath(c *cli.Context) string {
+	if val := c.String("config"); val != "" {
+		return val
+	}
+
+	return defaultConfigPath()
+}
+
+// LoadConfig reads the config file defined by the global config flag. A
+// missing file results in an empty config.
+func LoadConfig(c *cli.Context) (*Config, error) {
+	cfg := &Config{
+		path: configPath(c),
+	}
+
+	content, err := ioutil.ReadFile(cfg.path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", cfg.path, err)
+	}
+
+	return cfg, nil
+}
+
+// ActiveContext resolves the context selected by the global context flag,
+// falling back to the current context of the config.
+func ActiveContext(c *cli.Context, cfg *Config) (*ConfigContext, error) {
+	name := cfg.Current
+
+	if c.IsSet("context") {
+		name = c.String("context")
+	}
+
+	if name == "" {
+		return nil, nil
+	}
+
+	ctx := cfg.Context(name)
+
+	if ctx == nil {
+		return nil, fmt.Errorf("context %s is not defined", name)
+	}
+
+	return ctx, nil
+}
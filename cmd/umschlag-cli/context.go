@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplContextList represents a row within context listing.
+var tmplContextList = `Name: {{ highlight .Name }}
+Server: {{ .Server }}
+Current: {{ .Current }}
+`
+
+// tmplContextShow represents a context within details view.
+var tmplContextShow = `Name: {{ highlight .Name }}
+Server: {{ .Server }}
+Token: {{ if .Token }}********{{ end }}
+Output: {{ .Output }}
+Current: {{ .Current }}
+`
+
+// tableContextList defines the columns within context listing.
+var tableContextList = []outputColumn{
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "SERVER", Value: `{{ .Server }}`},
+	{Title: "CURRENT", Value: `{{ .Current }}`},
+	{Title: "OUTPUT", Value: `{{ .Output }}`, Wide: true},
+}
+
+// tableContextShow defines the columns within context details view.
+var tableContextShow = []outputColumn{
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "SERVER", Value: `{{ .Server }}`},
+	{Title: "TOKEN", Value: `{{ if .Token }}********{{ end }}`},
+	{Title: "OUTPUT", Value: `{{ .Output }}`},
+	{Title: "CURRENT", Value: `{{ .Current }}`},
+}
+
+// contextRecord represents a context with the current marker for output.
+type contextRecord struct {
+	XMLName xml.Name `json:"-" xml:"context"`
+	Name    string   `json:"name" xml:"name"`
+	Server  string   `json:"server" xml:"server"`
+	Token   string   `json:"-" xml:"-"`
+	Output  string   `json:"output,omitempty" xml:"output,omitempty"`
+	Current bool     `json:"current" xml:"current"`
+}
+
+// Context provides the sub-command to manage server contexts.
+func Context() *cli.Command {
+	return &cli.Command{
+		Name:  "context",
+		Usage: "Context related sub-commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Aliases:   []string{"ls"},
+				Usage:     "List all contexts",
+				ArgsUsage: " ",
+				Flags:     listFlags(tmplContextList),
+				Action: func(c *cli.Context) error {
+					return HandleConfig(c, ContextList)
+				},
+			},
+			{
+				Name:      "show",
+				Usage:     "Display a context",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Value: "",
+						Usage: "Context to show, defaults to the current one",
+					},
+				}, outputFlags(tmplContextShow)...),
+				Action: func(c *cli.Context) error {
+					return HandleConfig(c, ContextShow)
+				},
+			},
+			{
+				Name:      "add",
+				Usage:     "Add a context",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Value: "",
+						Usage: "Provide a name",
+					},
+					&cli.StringFlag{
+						Name:  "server",
+						Value: "",
+						Usage: "Provide a server",
+					},
+					&cli.StringFlag{
+						Name:  "token",
+						Value: "",
+						Usage: "Provide a token",
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Value: "",
+						Usage: "Default output format, can be " + strings.Join(outputFormats, ", "),
+					},
+					&cli.BoolFlag{
+						Name:  "use",
+						Value: false,
+						Usage: "Switch to the context after adding it",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return HandleConfig(c, ContextAdd)
+				},
+			},
+			{
+				Name:      "use",
+				Usage:     "Switch the current context",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Value: "",
+						Usage: "Context to switch to",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return HandleConfig(c, ContextUse)
+				},
+			},
+			{
+				Name:      "remove",
+				Aliases:   []string{"rm"},
+				Usage:     "Remove a context",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Value: "",
+						Usage: "Context to remove",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return HandleConfig(c, ContextRemove)
+				},
+			},
+		},
+	}
+}
+
+// ContextList provides the sub-command to list all contexts.
+func ContextList(c *cli.Context, cfg *Config) error {
+	records := make([]*contextRecord, 0, len(cfg.Contexts))
+
+	for _, ctx := range cfg.Contexts {
+		records = append(records, newContextRecord(cfg, ctx))
+	}
+
+	return RenderList(c, records, tableContextList)
+}
+
+// ContextShow provides the sub-command to show context details.
+func ContextShow(c *cli.Context, cfg *Config) error {
+	name := c.String("name")
+
+	if name == "" {
+		name = cfg.Current
+	}
+
+	if name == "" {
+		return fmt.Errorf("there is no current context, provide a name")
+	}
+
+	ctx := cfg.Context(name)
+
+	if ctx == nil {
+		return fmt.Errorf("context %s is not defined", name)
+	}
+
+	record := newContextRecord(cfg, ctx)
+
+	return RenderRecord(c, record, tableContextShow)
+}
+
+// ContextAdd provides the sub-command to add a context.
+func ContextAdd(c *cli.Context, cfg *Config) error {
+	record := &ConfigContext{}
+
+	if val := c.String("name"); val != "" {
+		record.Name = val
+	} else {
+		return fmt.Errorf("you must provide a name")
+	}
+
+	if cfg.Context(record.Name) != nil {
+		return fmt.Errorf("context %s is already defined", record.Name)
+	}
+
+	if val := c.String("server"); val != "" {
+		if _, err := url.Parse(val); err != nil {
+			return fmt.Errorf("invalid server address, bad format?")
+		}
+
+		record.Server = val
+	} else {
+		return fmt.Errorf("you must provide a server")
+	}
+
+	if val := c.String("token"); val != "" {
+		record.Token = val
+	}
+
+	if val := c.String("output"); val != "" {
+		if !validOutput(val) {
+			return fmt.Errorf("invalid output, can be %s", strings.Join(outputFormats, ", "))
+		}
+
+		record.Output = val
+	}
+
+	cfg.Contexts = append(cfg.Contexts, record)
+
+	if c.Bool("use") || cfg.Current == "" {
+		cfg.Current = record.Name
+	}
+
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully added\n")
+	return nil
+}
+
+// ContextUse provides the sub-command to switch the current context.
+func ContextUse(c *cli.Context, cfg *Config) error {
+	name := c.String("name")
+
+	if name == "" {
+		return fmt.Errorf("you must provide a name")
+	}
+
+	if cfg.Context(name) == nil {
+		return fmt.Errorf("context %s is not defined", name)
+	}
+
+	cfg.Current = name
+
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully switched to %s\n", name)
+	return nil
+}
+
+// ContextRemove provides the sub-command to remove a context.
+func ContextRemove(c *cli.Context, cfg *Config) error {
+	name := c.String("name")
+
+	if name == "" {
+		return fmt.Errorf("you must provide a name")
+	}
+
+	if !cfg.Remove(name) {
+		return fmt.Errorf("context %s is not defined", name)
+	}
+
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully removed\n")
+	return nil
+}
+
+// newContextRecord converts a config context into a record for output.
+func newContextRecord(cfg *Config, ctx *ConfigContext) *contextRecord {
+	return &contextRecord{
+		Name:    ctx.Name,
+		Server:  ctx.Server,
+		Token:   ctx.Token,
+		Output:  ctx.Output,
+		Current: cfg.Current == ctx.Name,
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// dockerCredentialHelper defines the binary name docker expects for this
+// credential helper, invoking the binary by that name enables the helper mode.
+const dockerCredentialHelper = "docker-credential-umschlag"
+
+// errCredentialsNotFound is the message docker expects for unknown servers.
+const errCredentialsNotFound = "credentials not found in native keychain"
+
+// credentialHelperFunc is the implementation of a credential helper command,
+// it reads the request from in and writes the response to out.
+type credentialHelperFunc func(c *cli.Context, client umschlag.ClientAPI, in io.Reader, out io.Writer) error
+
+// dockerCredentials represents the payload of the credential helper protocol.
+type dockerCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// CredentialHelper provides the sub-command for the docker credential helper.
+func CredentialHelper() *cli.Command {
+	return &cli.Command{
+		Name:  "credential-helper",
+		Usage: "Docker credential helper protocol",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "get",
+				Usage:     "Print credentials for a server read from stdin",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) error {
+					return Handle(c, credentialHelperAction(CredentialHelperGet))
+				},
+			},
+			{
+				Name:      "store",
+				Usage:     "Accept credentials read from stdin",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) error {
+					return Handle(c, credentialHelperAction(CredentialHelperStore))
+				},
+			},
+			{
+				Name:      "erase",
+				Usage:     "Accept erasing credentials for a server read from stdin",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) error {
+					return Handle(c, credentialHelperAction(CredentialHelperErase))
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "List all servers with credentials",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) error {
+					return Handle(c, credentialHelperAction(CredentialHelperList))
+				},
+			},
+		},
+	}
+}
+
+// CredentialHelperGet prints the credentials for a registry managed by
+// Umschlag, the credentials are derived from the Umschlag token.
+func CredentialHelperGet(c *cli.Context, client umschlag.ClientAPI, in io.Reader, out io.Writer) error {
+	server, err := readServerURL(in)
+
+	if err != nil {
+		return err
+	}
+
+	registry, err := findRegistry(client, server)
+
+	if err != nil {
+		return err
+	}
+
+	if registry == nil {
+		return fmt.Errorf(errCredentialsNotFound)
+	}
+
+	username, secret, err := registryCredentials(c, client)
+
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(out).Encode(dockerCredentials{
+		ServerURL: server,
+		Username:  username,
+		Secret:    secret,
+	})
+}
+
+// CredentialHelperStore accepts credentials for registries managed by
+// Umschlag. Nothing gets persisted as the credentials are always derived from
+// the Umschlag token.
+func CredentialHelperStore(c *cli.Context, client umschlag.ClientAPI, in io.Reader, out io.Writer) error {
+	creds := dockerCredentials{}
+
+	if err := json.NewDecoder(in).Decode(&creds); err != nil {
+		return fmt.Errorf("failed to parse credentials: %s", err)
+	}
+
+	registry, err := findRegistry(client, creds.ServerURL)
+
+	if err != nil {
+		return err
+	}
+
+	if registry == nil {
+		return fmt.Errorf("registry %s is not managed by umschlag", creds.ServerURL)
+	}
+
+	return nil
+}
+
+// CredentialHelperErase accepts erasing credentials, use logout to drop the
+// Umschlag token instead.
+func CredentialHelperErase(c *cli.Context, client umschlag.ClientAPI, in io.Reader, out io.Writer) error {
+	_, err := readServerURL(in)
+	return err
+}
+
+// CredentialHelperList prints all registries managed by Umschlag.
+func CredentialHelperList(c *cli.Context, client umschlag.ClientAPI, in io.Reader, out io.Writer) error {
+	records, err := client.RegistryList()
+
+	if err != nil {
+		return err
+	}
+
+	username, _, err := registryCredentials(c, client)
+
+	if err != nil {
+		return err
+	}
+
+	result := make(map[string]string, len(records))
+
+	for _, record := range records {
+		result[record.Host] = username
+	}
+
+	return json.NewEncoder(out).Encode(result)
+}
+
+// credentialHelperAction connects the credential helper to stdin and stdout
+// and exits with a non-zero exit code on errors.
+func credentialHelperAction(fn credentialHelperFunc) HandleFunc {
+	return func(c *cli.Context, client umschlag.ClientAPI) error {
+		if err := runCredentialHelper(c, client, fn, os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+
+		return nil
+	}
+}
+
+// runCredentialHelper reports errors the way docker expects them from a
+// credential helper, on the same stream as the response.
+func runCredentialHelper(c *cli.Context, client umschlag.ClientAPI, fn credentialHelperFunc, in io.Reader, out io.Writer) error {
+	if err := fn(c, client, in, out); err != nil {
+		fmt.Fprintf(out, "%s\n", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// readServerURL reads the server address passed by docker on stdin.
+func readServerURL(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	line = strings.TrimSpace(line)
+
+	if line == "" {
+		return "", fmt.Errorf("no server url provided")
+	}
+
+	return line, nil
+}
+
+// findRegistry returns the registry matching the host of the server address.
+func findRegistry(client umschlag.ClientAPI, server string) (*umschlag.Registry, error) {
+	records, err := client.RegistryList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	host := normalizeHost(server)
+
+	for _, record := range records {
+		if normalizeHost(record.Host) == host {
+			return record, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// registryCredentials derives registry credentials from the Umschlag token.
+func registryCredentials(c *cli.Context, client umschlag.ClientAPI) (string, string, error) {
+	token := c.String("token")
+
+	if token == "" {
+		return "", "", fmt.Errorf("not logged in, use login or provide a token")
+	}
+
+	creds, err := LoadCredentials(c)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if cred := creds.Get(c.String("server")); cred != nil && cred.Username != "" && cred.Token == token {
+		return cred.Username, token, nil
+	}
+
+	profile, err := client.ProfileGet()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return profile.Username, token, nil
+}
+
+// normalizeHost strips scheme, path and casing from a registry address.
+func normalizeHost(val string) string {
+	if strings.Contains(val, "://") {
+		if u, err := url.Parse(val); err == nil {
+			val = u.Host
+		}
+	}
+
+	if i := strings.Index(val, "/"); i >= 0 {
+		val = val[:i]
+	}
+
+	return strings.ToLower(val)
+}
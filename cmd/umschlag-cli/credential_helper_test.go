@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// testToken defines the token accepted by the fake API server.
+const testToken = "secret-token"
+
+// newTestAPI starts a fake Umschlag API serving the given registries and the
+// profile of the admin user, all requests require the test token.
+func newTestAPI(t *testing.T, registries []*umschlag.Registry) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"message": "unauthorized"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/registries":
+			json.NewEncoder(w).Encode(registries)
+		case "/api/profile/self":
+			json.NewEncoder(w).Encode(&umschlag.Profile{ID: 1, Slug: "admin", Username: "admin"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "not found"})
+		}
+	}))
+}
+
+// newTestContext creates a context with the global flags, the config file
+// points into an empty temporary directory.
+func newTestContext(t *testing.T, server, token string) (*cli.Context, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "umschlag-cli")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("server", server, "")
+	set.String("token", token, "")
+	set.String("config", filepath.Join(dir, "config.yml"), "")
+
+	return cli.NewContext(nil, set, nil), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+// runTestHelper executes a credential helper command against the fake API.
+func runTestHelper(t *testing.T, fn credentialHelperFunc, token, input string) (string, error) {
+	t.Helper()
+
+	api := newTestAPI(t, []*umschlag.Registry{
+		{ID: 1, Slug: "hub", Name: "Hub", Host: "registry.example.com"},
+		{ID: 2, Slug: "quay", Name: "Quay", Host: "quay.example.com:5000"},
+	})
+
+	defer api.Close()
+
+	c, cleanup := newTestContext(t, api.URL, token)
+	defer cleanup()
+
+	client := umschlag.NewClient(api.URL)
+
+	if token != "" {
+		client = umschlag.NewClientToken(api.URL, token)
+	}
+
+	out := &bytes.Buffer{}
+
+	err := runCredentialHelper(c, client, fn, strings.NewReader(input), out)
+	return out.String(), err
+}
+
+func TestCredentialHelperGet(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		host  string
+	}{
+		{name: "plain host", input: "registry.example.com\n", host: "registry.example.com"},
+		{name: "url with path", input: "https://registry.example.com/v2/\n", host: "https://registry.example.com/v2/"},
+		{name: "host with port", input: "Quay.Example.com:5000", host: "Quay.Example.com:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runTestHelper(t, CredentialHelperGet, testToken, tt.input)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			creds := dockerCredentials{}
+
+			if err := json.Unmarshal([]byte(out), &creds); err != nil {
+				t.Fatalf("failed to parse %q: %s", out, err)
+			}
+
+			expected := dockerCredentials{
+				ServerURL: tt.host,
+				Username:  "admin",
+				Secret:    testToken,
+			}
+
+			if creds != expected {
+				t.Errorf("expected %+v, got %+v", expected, creds)
+			}
+		})
+	}
+}
+
+func TestCredentialHelperGetNotFound(t *testing.T) {
+	out, err := runTestHelper(t, CredentialHelperGet, testToken, "unknown.example.com\n")
+
+	if err == nil {
+		t.Fatal("expected an error for an unknown registry")
+	}
+
+	if out != errCredentialsNotFound+"\n" {
+		t.Errorf("expected %q on stdout, got %q", errCredentialsNotFound, out)
+	}
+}
+
+func TestCredentialHelperGetInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		input string
+		err   string
+	}{
+		{name: "empty input", token: testToken, input: "", err: "no server url provided"},
+		{name: "missing token", token: "", input: "registry.example.com\n", err: ""},
+		{name: "invalid token", token: "invalid", input: "registry.example.com\n", err: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runTestHelper(t, CredentialHelperGet, tt.token, tt.input)
+
+			if err == nil {
+				t.Fatalf("expected an error, got %q", out)
+			}
+
+			if !strings.Contains(out, tt.err) {
+				t.Errorf("expected %q within %q", tt.err, out)
+			}
+		})
+	}
+}
+
+func TestCredentialHelperStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "managed", input: `{"ServerURL":"registry.example.com","Username":"admin","Secret":"x"}`},
+		{name: "unmanaged", input: `{"ServerURL":"unknown.example.com","Username":"admin","Secret":"x"}`, err: "not managed by umschlag"},
+		{name: "invalid", input: `{`, err: "failed to parse credentials"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runTestHelper(t, CredentialHelperStore, testToken, tt.input)
+
+			if tt.err == "" {
+				if err != nil || out != "" {
+					t.Fatalf("expected silent success, got %q and %v", out, err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(out, tt.err) {
+				t.Errorf("expected %q within %q", tt.err, out)
+			}
+		})
+	}
+}
+
+func TestCredentialHelperErase(t *testing.T) {
+	if out, err := runTestHelper(t, CredentialHelperErase, testToken, "registry.example.com\n"); err != nil || out != "" {
+		t.Fatalf("expected silent success, got %q and %v", out, err)
+	}
+
+	if out, err := runTestHelper(t, CredentialHelperErase, testToken, "\n"); err == nil {
+		t.Fatalf("expected an error for an empty server, got %q", out)
+	}
+}
+
+func TestCredentialHelperList(t *testing.T) {
+	out, err := runTestHelper(t, CredentialHelperList, testToken, "")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result := map[string]string{}
+
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("failed to parse %q: %s", out, err)
+	}
+
+	expected := map[string]string{
+		"registry.example.com":  "admin",
+		"quay.example.com:5000": "admin",
+	}
+
+	if len(result) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+
+	for host, username := range expected {
+		if result[host] != username {
+			t.Errorf("expected %s for %s, got %q", username, host, result[host])
+		}
+	}
+}
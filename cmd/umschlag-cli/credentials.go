@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// Credentials represents the persisted credentials file.
+type Credentials struct {
+	Servers []*Credential `yaml:"servers,omitempty"`
+
+	path string
+}
+
+// Credential represents the stored token for a single server.
+type Credential struct {
+	Server   string `yaml:"server"`
+	Username string `yaml:"username,omitempty"`
+	Token    string `yaml:"token"`
+}
+
+// Get returns the credential for the given server.
+func (creds *Credentials) Get(server string) *Credential {
+	server = normalizeServer(server)
+
+	for _, cred := range creds.Servers {
+		if cred.Server == server {
+			return cred
+		}
+	}
+
+	return nil
+}
+
+// Set stores the credential for the given server, replacing existing ones.
+func (creds *Credentials) Set(server, username, token string) {
+	server = normalizeServer(server)
+
+	if cred := creds.Get(server); cred != nil {
+		cred.Username = username
+		cred.Token = token
+
+		return
+	}
+
+	creds.Servers = append(creds.Servers, &Credential{
+		Server:   server,
+		Username: username,
+		Token:    token,
+	})
+}
+
+// Remove drops the credential for the given server.
+func (creds *Credentials) Remove(server string) bool {
+	server = normalizeServer(server)
+
+	for i, cred := range creds.Servers {
+		if cred.Server == server {
+			creds.Servers = append(creds.Servers[:i], creds.Servers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Save writes the credentials back to the file they were loaded from, the
+// file is always restricted to the current user.
+func (creds *Credentials) Save() error {
+	if err := os.MkdirAll(filepath.Dir(creds.path), 0700); err != nil {
+		return err
+	}
+
+	res, err := yaml.Marshal(creds)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(creds.path, res, 0600); err != nil {
+		return err
+	}
+
+	return os.Chmod(creds.path, 0600)
+}
+
+// LoadCredentials reads the credentials file stored next to the config file.
+// A missing file results in empty credentials.
+func LoadCredentials(c *cli.Context) (*Credentials, error) {
+	creds := &Credentials{
+		path: filepath.Join(
+			filepath.Dir(configPath(c)),
+			"credentials.yml",
+		),
+	}
+
+	content, err := ioutil.ReadFile(creds.path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return creds, nil
+		}
+
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, creds); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", creds.path, err)
+	}
+
+	return creds, nil
+}
+
+// normalizeServer strips trailing slashes to get consistent lookups.
+func normalizeServer(server string) string {
+	return strings.TrimRight(server, "/")
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron"
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// jobNamePattern defines the allowed job names, they are part of lock files.
+var jobNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// errJobLocked gets returned if the lock of a job is held by another run.
+var errJobLocked = errors.New("locked by another run")
+
+// DaemonConfig represents the jobs file of the daemon.
+type DaemonConfig struct {
+	Listen      string       `yaml:"listen"`
+	Concurrency int          `yaml:"concurrency"`
+	LockDir     string       `yaml:"lock_dir"`
+	History     int          `yaml:"history"`
+	Jobs        []*DaemonJob `yaml:"jobs"`
+}
+
+// DaemonJob represents a single scheduled job, either a sync or a prune.
+type DaemonJob struct {
+	Name     string          `yaml:"name"`
+	Schedule string          `yaml:"schedule"`
+	Jitter   string          `yaml:"jitter"`
+	Timeout  string          `yaml:"timeout"`
+	Sync     *DaemonSyncJob  `yaml:"sync"`
+	Prune    *DaemonPruneJob `yaml:"prune"`
+
+	schedule cron.Schedule
+	jitter   time.Duration
+	timeout  time.Duration
+	sync     *SyncOptions
+	prune    *PruneOptions
+}
+
+// DaemonSyncJob defines the registry to sync.
+type DaemonSyncJob struct {
+	Registry string `yaml:"registry"`
+	Wait     bool   `yaml:"wait"`
+	Interval string `yaml:"interval"`
+}
+
+// DaemonPruneJob defines the retention rules to prune tags.
+type DaemonPruneJob struct {
+	Repo             string `yaml:"repo"`
+	Org              string `yaml:"org"`
+	KeepLast         int    `yaml:"keep_last"`
+	OlderThan        string `yaml:"older_than"`
+	Match            string `yaml:"match"`
+	Exclude          string `yaml:"exclude"`
+	KeepSemverLatest bool   `yaml:"keep_semver_latest"`
+	Concurrency      int    `yaml:"concurrency"`
+	DryRun           bool   `yaml:"dry_run"`
+}
+
+// DaemonRun represents a single run within the job history.
+type DaemonRun struct {
+	Job      string    `json:"job"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Status   string    `json:"status"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// LoadDaemonConfig reads and validates the jobs file.
+func LoadDaemonConfig(path string) (*DaemonConfig, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &DaemonConfig{}
+
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate applies the defaults and parses the schedules and rules of all
+// jobs, that way invalid jobs are detected before the daemon starts.
+func (cfg *DaemonConfig) Validate() error {
+	if cfg.Listen == "" {
+		cfg.Listen = ":9001"
+	}
+
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+
+	if cfg.LockDir == "" {
+		cfg.LockDir = os.TempDir()
+	}
+
+	if cfg.History < 1 {
+		cfg.History = 20
+	}
+
+	if len(cfg.Jobs) == 0 {
+		return fmt.Errorf("no jobs defined")
+	}
+
+	names := map[string]bool{}
+
+	for _, job := range cfg.Jobs {
+		if !jobNamePattern.MatchString(job.Name) {
+			return fmt.Errorf("invalid job name %q", job.Name)
+		}
+
+		if names[job.Name] {
+			return fmt.Errorf("job %s defined twice", job.Name)
+		}
+
+		names[job.Name] = true
+
+		if err := job.validate(); err != nil {
+			return fmt.Errorf("job %s: %s", job.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate parses the schedule, durations and the job definition.
+func (job *DaemonJob) validate() error {
+	schedule, err := cron.ParseStandard(job.Schedule)
+
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %s", job.Schedule, err)
+	}
+
+	job.schedule = schedule
+	job.timeout = time.Hour
+
+	if job.Jitter != "" {
+		if job.jitter, err = time.ParseDuration(job.Jitter); err != nil {
+			return fmt.Errorf("invalid jitter %s: %s", job.Jitter, err)
+		}
+	}
+
+	if job.Timeout != "" {
+		if job.timeout, err = time.ParseDuration(job.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout %s: %s", job.Timeout, err)
+		}
+	}
+
+	switch {
+	case job.Sync != nil && job.Prune != nil:
+		return fmt.Errorf("conflict, a job can either sync or prune")
+	case job.Sync != nil:
+		if job.Sync.Registry == "" {
+			return fmt.Errorf("sync requires a registry")
+		}
+
+		opts := &SyncOptions{
+			Wait:     job.Sync.Wait,
+			Timeout:  job.timeout,
+			Interval: 2 * time.Second,
+		}
+
+		if job.Sync.Interval != "" {
+			if opts.Interval, err = time.ParseDuration(job.Sync.Interval); err != nil {
+				return fmt.Errorf("invalid interval %s: %s", job.Sync.Interval, err)
+			}
+		}
+
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+
+		job.sync = opts
+	case job.Prune != nil:
+		opts := &PruneOptions{
+			Repo:             job.Prune.Repo,
+			Org:              job.Prune.Org,
+			KeepLast:         job.Prune.KeepLast,
+			KeepSemverLatest: job.Prune.KeepSemverLatest,
+			Concurrency:      job.Prune.Concurrency,
+			Delete:           !job.Prune.DryRun,
+		}
+
+		if job.Prune.OlderThan != "" {
+			if opts.OlderThan, err = ParseAge(job.Prune.OlderThan); err != nil {
+				return fmt.Errorf("invalid older_than %s: %s", job.Prune.OlderThan, err)
+			}
+		}
+
+		if job.Prune.Match != "" {
+			if opts.Match, err = regexp.Compile(job.Prune.Match); err != nil {
+				return fmt.Errorf("invalid match %s: %s", job.Prune.Match, err)
+			}
+		}
+
+		if job.Prune.Exclude != "" {
+			if opts.Exclude, err = regexp.Compile(job.Prune.Exclude); err != nil {
+				return fmt.Errorf("invalid exclude %s: %s", job.Prune.Exclude, err)
+			}
+		}
+
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+
+		job.prune = opts
+	default:
+		return fmt.Errorf("you must define sync or prune")
+	}
+
+	return nil
+}
+
+// daemon schedules the jobs and keeps track of their runs.
+type daemon struct {
+	cfg    *DaemonConfig
+	client umschlag.ClientAPI
+	log    *logger
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	slots   chan struct{}
+	stopped bool
+	running map[string]bool
+	history map[string][]*DaemonRun
+}
+
+// schedule registers all jobs at the cron scheduler.
+func (d *daemon) schedule(scheduler *cron.Cron) {
+	for _, job := range d.cfg.Jobs {
+		job := job
+
+		scheduler.Schedule(job.schedule, cron.FuncJob(func() {
+			d.run(job)
+		}))
+	}
+}
+
+// run executes a job after the jitter once a slot is available, runs of the
+// same job never overlap within the process and across processes sharing the
+// lock directory.
+func (d *daemon) run(job *DaemonJob) {
+	d.mutex.Lock()
+
+	if d.stopped {
+		d.mutex.Unlock()
+		return
+	}
+
+	if d.running[job.Name] {
+		d.mutex.Unlock()
+		d.record(job, time.Now(), "skipped", "previous run still active")
+
+		return
+	}
+
+	d.running[job.Name] = true
+	d.wg.Add(1)
+	d.mutex.Unlock()
+
+	defer func() {
+		d.mutex.Lock()
+		delete(d.running, job.Name)
+		d.mutex.Unlock()
+
+		d.wg.Done()
+	}()
+
+	if job.jitter > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(job.jitter)))):
+		case <-d.ctx.Done():
+			return
+		}
+	}
+
+	select {
+	case d.slots <- struct{}{}:
+		defer func() { <-d.slots }()
+	case <-d.ctx.Done():
+		return
+	}
+
+	started := time.Now()
+	release, err := acquireLock(filepath.Join(d.cfg.LockDir, "umschlag-"+job.Name+".lock"), 2*job.timeout)
+
+	if err == errJobLocked {
+		d.record(job, started, "skipped", err.Error())
+		return
+	}
+
+	if err != nil {
+		d.record(job, started, "failed", err.Error())
+		return
+	}
+
+	defer release()
+
+	ctx, cancel := context.WithTimeout(d.ctx, job.timeout)
+	defer cancel()
+
+	d.log.Info("starting job", "job", job.Name)
+	message, err := d.execute(ctx, job)
+
+	if err != nil {
+		d.record(job, started, "failed", err.Error())
+		return
+	}
+
+	d.record(job, started, "success", message)
+}
+
+// execute runs the sync or prune of the job, both stop once the context
+// gets canceled by the timeout of the job or the shutdown of the daemon.
+func (d *daemon) execute(ctx context.Context, job *DaemonJob) (string, error) {
+	if job.Sync != nil {
+		status, err := SyncRegistry(ctx, d.client, job.Sync.Registry, job.sync)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !job.Sync.Wait {
+			return "triggered sync", nil
+		}
+
+		return fmt.Sprintf("synced %d orgs, %d repos, %d tags", status.Orgs, status.Repos, status.Tags), nil
+	}
+
+	result, err := Prune(ctx, d.client, job.prune)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !job.prune.Delete {
+		return fmt.Sprintf("dry run, would delete %d of %d tags", result.Deleted, result.Total), nil
+	}
+
+	if result.Failed > 0 {
+		return "", fmt.Errorf("failed to delete %d of %d tags", result.Failed, result.Deleted+result.Failed)
+	}
+
+	return fmt.Sprintf("deleted %d of %d tags, kept %d", result.Deleted, result.Total, result.Kept), nil
+}
+
+// record appends the run to the limited history of the job and logs it.
+func (d *daemon) record(job *DaemonJob, started time.Time, status, message string) {
+	run := &DaemonRun{
+		Job:      job.Name,
+		Started:  started,
+		Finished: time.Now(),
+		Status:   status,
+		Message:  message,
+	}
+
+	d.mutex.Lock()
+	history := append(d.history[job.Name], run)
+
+	if len(history) > d.cfg.History {
+		history = history[len(history)-d.cfg.History:]
+	}
+
+	d.history[job.Name] = history
+	d.mutex.Unlock()
+
+	keyvals := []interface{}{"job", job.Name, "status", status, "duration", run.Finished.Sub(started).Round(time.Millisecond), "result", message}
+
+	switch status {
+	case "failed":
+		d.log.Error("finished job", keyvals...)
+	case "skipped":
+		d.log.Warn("skipped job", keyvals...)
+	default:
+		d.log.Info("finished job", keyvals...)
+	}
+}
+
+// health renders the state of all jobs including their last run.
+func (d *daemon) health(w http.ResponseWriter, r *http.Request) {
+	type jobState struct {
+		Name    string     `json:"name"`
+		Next    time.Time  `json:"next"`
+		Running bool       `json:"running"`
+		Last    *DaemonRun `json:"last,omitempty"`
+	}
+
+	d.mutex.Lock()
+
+	result := struct {
+		Status string      `json:"status"`
+		Jobs   []*jobState `json:"jobs"`
+	}{
+		Status: "ok",
+	}
+
+	if d.stopped {
+		result.Status = "stopping"
+	}
+
+	for _, job := range d.cfg.Jobs {
+		state := &jobState{
+			Name:    job.Name,
+			Next:    job.schedule.Next(time.Now()),
+			Running: d.running[job.Name],
+		}
+
+		if history := d.history[job.Name]; len(history) > 0 {
+			state.Last = history[len(history)-1]
+		}
+
+		result.Jobs = append(result.Jobs, state)
+	}
+
+	d.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if result.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// runs renders the history of all jobs.
+func (d *daemon) runs(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+
+	result := []*DaemonRun{}
+
+	for _, job := range d.cfg.Jobs {
+		result = append(result, d.history[job.Name]...)
+	}
+
+	d.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// acquireLock creates the lock file exclusively, locks older than stale are
+// treated as left over by a crashed run and get replaced.
+func acquireLock(path string, stale time.Duration) (func(), error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+
+		if err == nil {
+			hostname, _ := os.Hostname()
+			fmt.Fprintf(file, "%s:%d\n", hostname, os.Getpid())
+			file.Close()
+
+			return func() { os.Remove(path) }, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		info, err := os.Stat(path)
+
+		if err != nil || time.Since(info.ModTime()) < stale {
+			return nil, errJobLocked
+		}
+
+		os.Remove(path)
+	}
+
+	return nil, errJobLocked
+}
+
+// Daemon provides the sub-command to run scheduled jobs.
+func Daemon() *cli.Command {
+	return &cli.Command{
+		Name:      "daemon",
+		Usage:     "Run scheduled syncs and prunes",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "jobs",
+				Value:   "",
+				Usage:   "Path to the jobs file",
+				EnvVars: []string{"UMSCHLAG_DAEMON_JOBS"},
+			},
+			&cli.StringFlag{
+				Name:  "listen",
+				Value: "",
+				Usage: "Address for the health endpoint, overrides the jobs file",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return Handle(c, DaemonAction)
+		},
+	}
+}
+
+// DaemonAction runs the jobs until the process receives SIGINT or SIGTERM,
+// active runs get canceled and the daemon waits for them before it exits.
+func DaemonAction(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.String("jobs") == "" {
+		return fmt.Errorf("you must provide a jobs file")
+	}
+
+	cfg, err := LoadDaemonConfig(c.String("jobs"))
+
+	if err != nil {
+		return err
+	}
+
+	if val := c.String("listen"); val != "" {
+		cfg.Listen = val
+	}
+
+	rand.Seed(time.Now().UnixNano())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := &daemon{
+		cfg:     cfg,
+		ctx:     ctx,
+		cancel:  cancel,
+		client:  client,
+		log:     newLogger(os.Stderr),
+		slots:   make(chan struct{}, cfg.Concurrency),
+		running: map[string]bool{},
+		history: map[string][]*DaemonRun{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.health)
+	mux.HandleFunc("/history", d.runs)
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	scheduler := cron.New()
+	d.schedule(scheduler)
+	scheduler.Start()
+
+	d.log.Info("started daemon", "jobs", len(cfg.Jobs), "concurrency", cfg.Concurrency, "addr", cfg.Listen)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err = <-errs:
+	case sig := <-stop:
+		d.log.Info("shutting down", "signal", sig)
+	}
+
+	scheduler.Stop()
+
+	d.mutex.Lock()
+	d.stopped = true
+	d.cancel()
+	d.mutex.Unlock()
+
+	d.wg.Wait()
+
+	shutdown, release := context.WithTimeout(context.Background(), 10*time.Second)
+	defer release()
+
+	server.Shutdown(shutdown)
+	d.log.Info("stopped daemon")
+
+	return err
+}
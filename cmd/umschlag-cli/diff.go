@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplRegistryDiff represents a row within the registry diff.
+var tmplRegistryDiff = `{{ if eq .Status "missing" }}{{ success "+" }}{{ else }}{{ danger "-" }}{{ end }} {{ .Kind }} {{ highlight .Name }} ({{ .Status }}){{ with .Detail }}: {{ . }}{{ end }}{{ with .Fix }} [{{ . }}]{{ end }}`
+
+// tableRegistryDiff defines the columns within the registry diff.
+var tableRegistryDiff = []outputColumn{
+	{Title: "KIND", Value: `{{ .Kind }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "STATUS", Value: `{{ .Status }}`},
+	{Title: "ID", Value: `{{ with .ID }}{{ . }}{{ end }}`, Wide: true},
+	{Title: "DETAIL", Value: `{{ .Detail }}`, Wide: true},
+	{Title: "FIX", Value: `{{ .Fix }}`},
+}
+
+// registryDiffRecord represents a single difference between the registry and
+// the records known to Umschlag. Missing entries only exist on the registry,
+// extra repos and stale tags only exist within Umschlag.
+type registryDiffRecord struct {
+	XMLName xml.Name `json:"-" xml:"entry"`
+	Kind    string   `json:"kind" xml:"kind"`
+	Name    string   `json:"name" xml:"name"`
+	Status  string   `json:"status" xml:"status"`
+	ID      int64    `json:"id,omitempty" xml:"id,omitempty"`
+	Detail  string   `json:"detail,omitempty" xml:"detail,omitempty"`
+	Fix     string   `json:"fix,omitempty" xml:"fix,omitempty"`
+}
+
+// DiffRegistry compares the catalog of the registry with the repos and tags
+// known to Umschlag for this registry.
+func DiffRegistry(c *cli.Context, client umschlag.ClientAPI, registry *umschlag.Registry) ([]*registryDiffRecord, error) {
+	orgs, err := client.OrgList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	orgIDs := map[int64]bool{}
+
+	for _, org := range orgs {
+		if org.RegistryID == registry.ID {
+			orgIDs[org.ID] = true
+		}
+	}
+
+	repos, err := client.RepoList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]*umschlag.Repo{}
+	names := map[int64]string{}
+
+	for _, repo := range repos {
+		if orgIDs[repo.OrgID] {
+			known[repo.FullName] = repo
+			names[repo.ID] = repo.FullName
+		}
+	}
+
+	tags, err := client.TagList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	knownTags := map[string]map[string]*umschlag.Tag{}
+
+	for _, tag := range tags {
+		if name, ok := names[tag.RepoID]; ok {
+			if knownTags[name] == nil {
+				knownTags[name] = map[string]*umschlag.Tag{}
+			}
+
+			knownTags[name][tag.Name] = tag
+		}
+	}
+
+	remote, err := newRegistryClient(c, client, registry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, err := remote.Catalog()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(catalog)
+
+	records := []*registryDiffRecord{}
+	found := map[string]bool{}
+
+	for _, name := range catalog {
+		found[name] = true
+		available, err := remote.Tags(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(available)
+
+		if _, ok := known[name]; !ok {
+			records = append(records, &registryDiffRecord{
+				Kind:   "repo",
+				Name:   name,
+				Status: "missing",
+				Detail: fmt.Sprintf("%d tags on the registry", len(available)),
+			})
+
+			continue
+		}
+
+		existing := map[string]bool{}
+
+		for _, tag := range available {
+			existing[tag] = true
+
+			if _, ok := knownTags[name][tag]; !ok {
+				records = append(records, &registryDiffRecord{
+					Kind:   "tag",
+					Name:   name + ":" + tag,
+					Status: "missing",
+				})
+			}
+		}
+
+		stale := []string{}
+
+		for tag := range knownTags[name] {
+			if !existing[tag] {
+				stale = append(stale, tag)
+			}
+		}
+
+		sort.Strings(stale)
+
+		for _, tag := range stale {
+			records = append(records, &registryDiffRecord{
+				Kind:   "tag",
+				Name:   name + ":" + tag,
+				Status: "stale",
+				ID:     knownTags[name][tag].ID,
+				Detail: "deleted from the registry",
+			})
+		}
+	}
+
+	extra := []string{}
+
+	for name := range known {
+		if !found[name] {
+			extra = append(extra, name)
+		}
+	}
+
+	sort.Strings(extra)
+
+	for _, name := range extra {
+		records = append(records, &registryDiffRecord{
+			Kind:   "repo",
+			Name:   name,
+			Status: "extra",
+			ID:     known[name].ID,
+			Detail: fmt.Sprintf("not within the catalog, %d tags known", len(knownTags[name])),
+		})
+	}
+
+	return records, nil
+}
+
+// fixRegistryDiff triggers a sync for missing entries and deletes extra repos
+// and stale tags, the deletion has to be confirmed.
+func fixRegistryDiff(c *cli.Context, client umschlag.ClientAPI, registry *umschlag.Registry, records []*registryDiffRecord) int {
+	missing, obsolete := 0, 0
+
+	for _, record := range records {
+		if record.Status == "missing" {
+			missing++
+		} else {
+			obsolete++
+		}
+	}
+
+	failed := 0
+
+	if missing > 0 {
+		status := "sync triggered"
+
+		if err := client.RegistrySync(strconv.FormatInt(registry.ID, 10)); err != nil {
+			status = "sync failed: " + err.Error()
+			failed++
+		}
+
+		for _, record := range records {
+			if record.Status == "missing" {
+				record.Fix = status
+			}
+		}
+	}
+
+	if obsolete == 0 {
+		return failed
+	}
+
+	if !confirm(c, fmt.Sprintf("Delete %d records from %s which don't exist on the registry?", obsolete, registry.Name)) {
+		for _, record := range records {
+			if record.Status != "missing" {
+				record.Fix = "skipped"
+			}
+		}
+
+		return failed
+	}
+
+	for _, record := range records {
+		if record.Status == "missing" {
+			continue
+		}
+
+		var err error
+
+		switch record.Kind {
+		case "repo":
+			err = client.RepoDelete(strconv.FormatInt(record.ID, 10))
+		case "tag":
+			err = client.TagDelete(strconv.FormatInt(record.ID, 10))
+		}
+
+		if err != nil {
+			record.Fix = "delete failed: " + err.Error()
+			failed++
+		} else {
+			record.Fix = "deleted"
+		}
+	}
+
+	return failed
+}
+
+// confirm asks the question on a terminal, without a terminal the --yes flag
+// is required to proceed.
+func confirm(c *cli.Context, question string) bool {
+	if c.Bool("yes") {
+		return true
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "%s Skipped, use --yes to confirm without a terminal\n", question)
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+
+	return false
+}
+
+// RegistryDiff provides the sub-command to compare a registry with Umschlag.
+func RegistryDiff(c *cli.Context, client umschlag.ClientAPI) error {
+	registry, err := client.RegistryGet(
+		GetIdentifierParam(c),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	records, err := DiffRegistry(c, client, registry)
+
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	if c.Bool("fix") && len(records) > 0 {
+		failed = fixRegistryDiff(c, client, registry, records)
+	}
+
+	if err := RenderList(c, records, tableRegistryDiff); err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+
+	for _, record := range records {
+		counts[record.Status]++
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d missing, %d extra and %d stale entries for %s\n", counts["missing"], counts["extra"], counts["stale"], registry.Name)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to fix %d entries", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// exportVersion defines the version of the export document.
+const exportVersion = 1
+
+// tmplImport represents a row within the import report.
+var tmplImport = `{{ .Kind }} {{ highlight .Name }}: {{ .Status }}{{ with .Detail }} ({{ . }}){{ end }}`
+
+// tableImport defines the columns within the import report.
+var tableImport = []outputColumn{
+	{Title: "KIND", Value: `{{ .Kind }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "STATUS", Value: `{{ .Status }}`},
+	{Title: "DETAIL", Value: `{{ .Detail }}`},
+}
+
+// Backup represents a versioned document of all records on a server, the
+// records reference each other by slug or username instead of IDs.
+type Backup struct {
+	Version    int               `yaml:"version" json:"version"`
+	ExportedAt time.Time         `yaml:"exported_at" json:"exported_at"`
+	Server     string            `yaml:"server,omitempty" json:"server,omitempty"`
+	Registries []*BackupRegistry `yaml:"registries,omitempty" json:"registries,omitempty"`
+	State      `yaml:",inline"`
+}
+
+// BackupRegistry represents an exported registry, identified by the slug.
+type BackupRegistry struct {
+	Slug string `yaml:"slug" json:"slug"`
+	Name string `yaml:"name" json:"name"`
+	Host string `yaml:"host" json:"host"`
+}
+
+// importRecord represents a single entry within the import report.
+type importRecord struct {
+	XMLName xml.Name `json:"-" xml:"record" yaml:"-"`
+	Kind    string   `json:"kind" xml:"kind" yaml:"kind"`
+	Name    string   `json:"name" xml:"name" yaml:"name"`
+	Status  string   `json:"status" xml:"status" yaml:"status"`
+	Detail  string   `json:"detail,omitempty" xml:"detail,omitempty" yaml:"detail,omitempty"`
+
+	password string
+}
+
+// importReportRecord adds the temporary password of created users to the
+// records, it's only used for the report file and never printed.
+type importReportRecord struct {
+	importRecord `yaml:",inline"`
+	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// buildExport converts the records of the server into the export document,
+// memberships without the referenced record are reported as error.
+func buildExport(live *liveState, server string) (*Backup, error) {
+	doc := &Backup{
+		Version:    exportVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Server:     server,
+	}
+
+	for _, registry := range live.registries {
+		doc.Registries = append(doc.Registries, &BackupRegistry{
+			Slug: registry.Slug,
+			Name: registry.Name,
+			Host: registry.Host,
+		})
+	}
+
+	for _, org := range live.orgs {
+		doc.Orgs = append(doc.Orgs, &StateOrg{
+			Slug:     org.Slug,
+			Name:     org.Name,
+			Registry: live.registrySlug(org.RegistryID),
+		})
+	}
+
+	for _, team := range live.teams {
+		record := &StateTeam{
+			Slug:  team.Slug,
+			Name:  team.Name,
+			Users: map[string]string{},
+			Orgs:  map[string]string{},
+		}
+
+		for _, member := range live.teamUsers[team.ID] {
+			if member.User == nil {
+				return nil, fmt.Errorf("user membership of team %s without user", team.Slug)
+			}
+
+			record.Users[member.User.Username] = member.Perm
+		}
+
+		for _, member := range live.teamOrgs[team.ID] {
+			if member.Org == nil {
+				return nil, fmt.Errorf("org membership of team %s without org", team.Slug)
+			}
+
+			record.Orgs[member.Org.Slug] = member.Perm
+		}
+
+		doc.Teams = append(doc.Teams, record)
+	}
+
+	for _, user := range live.users {
+		active, admin := user.Active, user.Admin
+
+		record := &StateUser{
+			Username: user.Username,
+			Slug:     user.Slug,
+			Email:    user.Email,
+			Active:   &active,
+			Admin:    &admin,
+			Orgs:     map[string]string{},
+		}
+
+		for _, member := range live.userOrgs[user.ID] {
+			if member.Org == nil {
+				return nil, fmt.Errorf("org membership of user %s without org", user.Username)
+			}
+
+			record.Orgs[member.Org.Slug] = member.Perm
+		}
+
+		doc.Users = append(doc.Users, record)
+	}
+
+	return doc, nil
+}
+
+// LoadBackup reads an export document, JSON documents are parsed as YAML.
+func LoadBackup(path string) (*Backup, error) {
+	var (
+		content []byte
+		err     error
+	)
+
+	if path == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Backup{}
+
+	if err := yaml.UnmarshalStrict(content, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	if doc.Version == 0 || doc.Version > exportVersion {
+		return nil, fmt.Errorf("unsupported export version %d, expected %d", doc.Version, exportVersion)
+	}
+
+	registries := map[string]bool{}
+
+	for _, registry := range doc.Registries {
+		if registry.Slug == "" || registry.Host == "" {
+			return nil, fmt.Errorf("invalid %s: registry requires slug and host", path)
+		}
+
+		if registries[registry.Slug] {
+			return nil, fmt.Errorf("invalid %s: registry %s defined twice", path, registry.Slug)
+		}
+
+		registries[registry.Slug] = true
+	}
+
+	if err := doc.State.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", path, err)
+	}
+
+	return doc, nil
+}
+
+// randomPassword generates a password for imported users, as passwords are
+// never part of an export.
+func randomPassword() (string, error) {
+	buf := make([]byte, 24)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// Export provides the sub-command to export all records.
+func Export() *cli.Command {
+	return &cli.Command{
+		Name:      "export",
+		Usage:     "Export registries, orgs, teams, users and memberships",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "out",
+				Value: "-",
+				Usage: "Path to the export, the format depends on the extension, - prints YAML",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return Handle(c, ExportAction)
+		},
+	}
+}
+
+// ExportAction writes all records into a single document.
+func ExportAction(c *cli.Context, client umschlag.ClientAPI) error {
+	live, err := fetchLiveState(client)
+
+	if err != nil {
+		return err
+	}
+
+	doc, err := buildExport(live, c.String("server"))
+
+	if err != nil {
+		return err
+	}
+
+	path := c.String("out")
+
+	var content []byte
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		content, err = json.MarshalIndent(doc, "", "  ")
+		content = append(content, '\n')
+	} else {
+		content, err = yaml.Marshal(doc)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if path == "-" || path == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+
+	if err := writeFileAtomic(path, content, 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"Successfully exported %d registries, %d orgs, %d teams and %d users to %s\n",
+		len(doc.Registries),
+		len(doc.Orgs),
+		len(doc.Teams),
+		len(doc.Users),
+		path,
+	)
+
+	return nil
+}
+
+// Import provides the sub-command to import an export.
+func Import() *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Import registries, orgs, teams, users and memberships",
+		ArgsUsage: " ",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Value:   "",
+				Usage:   "Path to the export, use - for stdin",
+			},
+			&cli.BoolFlag{
+				Name:  "overwrite",
+				Value: false,
+				Usage: "Update existing records which differ from the export",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-users",
+				Value: false,
+				Usage: "Skip users missing on the server instead of using temporary passwords",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Value: false,
+				Usage: "Only report what would be imported",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Value: "",
+				Usage: "Write the report including temporary passwords to this path, the format depends on the extension",
+			},
+		}, outputFlags(tmplImport)...),
+		Action: func(c *cli.Context) error {
+			return Handle(c, ImportAction)
+		},
+	}
+}
+
+// ImportAction recreates the records of an export. Missing records get
+// created, differing records are reported as conflicts unless overwrite is
+// enabled, and nothing gets deleted. Running it twice doesn't change anything.
+func ImportAction(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.String("file") == "" {
+		return fmt.Errorf("you must provide an export file")
+	}
+
+	doc, err := LoadBackup(c.String("file"))
+
+	if err != nil {
+		return err
+	}
+
+	report, err := importBackup(client, doc, ImportOptions{
+		DryRun:          c.Bool("dry-run"),
+		Overwrite:       c.Bool("overwrite"),
+		SkipUsers:       c.Bool("skip-users"),
+		ReportPasswords: c.String("report") != "",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return finishImport(c, "import", report)
+}
+
+// ImportOptions defines the behavior of importing a backup document.
+type ImportOptions struct {
+	DryRun    bool
+	Overwrite bool
+
+	// SkipUsers skips users missing on the server instead of creating them
+	// with a random password.
+	SkipUsers bool
+
+	// ReportPasswords confirms the random passwords get written to a report
+	// file, otherwise missing users have to be skipped.
+	ReportPasswords bool
+}
+
+// importBackup creates the missing records of the document on the server
+// and returns a report of all records which have been touched or skipped.
+func importBackup(client umschlag.ClientAPI, doc *Backup, opts ImportOptions) ([]*importRecord, error) {
+	var (
+		dryRun    = opts.DryRun
+		overwrite = opts.Overwrite
+		report    = []*importRecord{}
+	)
+
+	live, err := fetchLiveState(client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, registry := range doc.Registries {
+		record := &importRecord{
+			Kind: "registry",
+			Name: registry.Slug,
+		}
+
+		var existing *umschlag.Registry
+
+		for _, current := range live.registries {
+			if current.Slug == registry.Slug {
+				existing = current
+				break
+			}
+		}
+
+		switch {
+		case existing == nil && dryRun:
+			record.Status = "would create"
+
+			live.registries = append(live.registries, &umschlag.Registry{
+				ID:   int64(-1 - i),
+				Slug: registry.Slug,
+			})
+		case existing == nil:
+			_, err := client.RegistryPost(&umschlag.Registry{
+				Slug: registry.Slug,
+				Name: registry.Name,
+				Host: registry.Host,
+			})
+
+			if err != nil {
+				record.Status = "failed"
+				record.Detail = err.Error()
+			} else {
+				record.Status = "created"
+			}
+		case existing.Name == registry.Name && existing.Host == registry.Host:
+			continue
+		case !overwrite:
+			record.Status = "conflict"
+			record.Detail = formatPlanFields(registryFields(existing, registry))
+		case dryRun:
+			record.Status = "would update"
+			record.Detail = formatPlanFields(registryFields(existing, registry))
+		default:
+			existing.Name = registry.Name
+			existing.Host = registry.Host
+
+			if _, err := client.RegistryPatch(existing); err != nil {
+				record.Status = "failed"
+				record.Detail = err.Error()
+			} else {
+				record.Status = "updated"
+			}
+		}
+
+		report = append(report, record)
+	}
+
+	if !dryRun {
+		if live.registries, err = client.RegistryList(); err != nil {
+			return nil, err
+		}
+	}
+
+	known := map[string]bool{}
+
+	for _, user := range live.users {
+		known[user.Username] = true
+	}
+
+	generated := map[string]string{}
+
+	if opts.SkipUsers {
+		report = append(report, skipMissingUsers(&doc.State, known)...)
+	}
+
+	for _, user := range doc.Users {
+		if known[user.Username] || user.Password != "" {
+			continue
+		}
+
+		if user.Password, err = randomPassword(); err != nil {
+			return nil, err
+		}
+
+		generated[user.Username] = user.Password
+	}
+
+	if len(generated) > 0 && !dryRun && !opts.ReportPasswords {
+		return nil, fmt.Errorf(
+			"%d users are missing on the server, provide --report to store their temporary passwords or --skip-users",
+			len(generated),
+		)
+	}
+
+	plan, err := PlanState(client, &doc.State, live, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range plan.Changes {
+		record := &importRecord{
+			Kind: change.Kind,
+			Name: change.Name,
+		}
+
+		report = append(report, record)
+
+		switch {
+		case change.Action == "update" && !overwrite:
+			record.Status = "conflict"
+			record.Detail = formatPlanFields(change.Fields)
+
+			continue
+		case dryRun:
+			record.Status = "would " + change.Action
+			record.Detail = formatPlanFields(change.Fields)
+
+			continue
+		}
+
+		if err := change.run(); err != nil {
+			record.Status = "failed"
+			record.Detail = err.Error()
+
+			continue
+		}
+
+		record.Status = change.Action + "d"
+
+		if password, ok := generated[change.Name]; ok && change.Kind == "user" {
+			record.Detail = "created with a temporary password, see the report"
+			record.password = password
+		}
+	}
+
+	return report, nil
+}
+
+// skipMissingUsers drops all users missing on the server from the state,
+// including their team memberships, and reports them as skipped.
+func skipMissingUsers(state *State, known map[string]bool) []*importRecord {
+	report := []*importRecord{}
+	users := []*StateUser{}
+
+	for _, user := range state.Users {
+		if known[user.Username] {
+			users = append(users, user)
+			continue
+		}
+
+		report = append(report, &importRecord{
+			Kind:   "user",
+			Name:   user.Username,
+			Status: "skipped",
+			Detail: "missing on the server, passwords are not copied",
+		})
+
+		for _, team := range state.Teams {
+			delete(team.Users, user.Username)
+		}
+	}
+
+	state.Users = users
+	return report
+}
+
+// finishImport renders the report, optionally writes it to the report path
+// and fails if any record failed to import.
+func finishImport(c *cli.Context, action string, report []*importRecord) error {
+	if err := RenderList(c, report, tableImport); err != nil {
+		return err
+	}
+
+	if path := c.String("report"); path != "" {
+		if err := writeImportReport(path, report); err != nil {
+			return err
+		}
+	}
+
+	counts := map[string]int{}
+
+	for _, record := range report {
+		counts[record.Status]++
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"%s finished: %d created, %d updated, %d conflicts, %d skipped, %d failed\n",
+		strings.Title(action),
+		counts["created"],
+		counts["updated"],
+		counts["conflict"],
+		counts["skipped"],
+		counts["failed"],
+	)
+
+	if counts["failed"] > 0 {
+		return fmt.Errorf("failed to %s %d records", action, counts["failed"])
+	}
+
+	return nil
+}
+
+// registryFields lists the attributes of a registry differing from the export.
+func registryFields(existing *umschlag.Registry, registry *BackupRegistry) []planField {
+	fields := []planField{}
+
+	if existing.Name != registry.Name {
+		fields = append(fields, planField{Name: "name", Old: existing.Name, New: registry.Name})
+	}
+
+	if existing.Host != registry.Host {
+		fields = append(fields, planField{Name: "host", Old: existing.Host, New: registry.Host})
+	}
+
+	return fields
+}
+
+// formatPlanFields formats the changed fields for the import report.
+func formatPlanFields(fields []planField) string {
+	res := []string{}
+
+	for _, field := range fields {
+		switch {
+		case field.Old == "":
+			res = append(res, fmt.Sprintf("%s: %s", field.Name, field.New))
+		default:
+			res = append(res, fmt.Sprintf("%s: %s => %s", field.Name, field.Old, field.New))
+		}
+	}
+
+	return strings.Join(res, ", ")
+}
+
+// writeImportReport writes the report as JSON or YAML depending on the path,
+// reports containing temporary passwords are only readable by the owner.
+func writeImportReport(path string, report []*importRecord) error {
+	var (
+		content []byte
+		err     error
+		records = make([]importReportRecord, 0, len(report))
+		secret  = false
+	)
+
+	for _, record := range report {
+		records = append(records, importReportRecord{
+			importRecord: *record,
+			Password:     record.password,
+		})
+
+		if record.password != "" {
+			secret = true
+		}
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		content, err = json.MarshalIndent(records, "", "  ")
+		content = append(content, '\n')
+	} else {
+		content, err = yaml.Marshal(records)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if secret {
+		return writeSecretFile(path, content)
+	}
+
+	return writeFileAtomic(path, content, 0644)
+}
@@ -9,14 +9,67 @@ import (
 	"gopkg.in/urfave/cli.v2"
 )
 
+// defaultServer defines the server address if nothing else is configured.
+const defaultServer = "http://localhost:8080"
+
 // HandleFunc is the real handle implementation.
 type HandleFunc func(c *cli.Context, client umschlag.ClientAPI) error
 
+// ConfigFunc is the real handle implementation for config commands.
+type ConfigFunc func(c *cli.Context, cfg *Config) error
+
+// exitError defines a distinct exit code for the error of a command.
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error implements the error interface.
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// exitCode returns the exit code for the error of a command, all errors
+// without a distinct exit code exit with 2.
+func exitCode(err error) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+
+	return 2
+}
+
 // Handle wraps the command function handler.
 func Handle(c *cli.Context, fn HandleFunc) error {
+	cfg, err := LoadConfig(c)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := SetupColor(c, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	ctx, err := ActiveContext(c, cfg)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	creds, err := LoadCredentials(c)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
 	var (
-		server = c.String("server")
-		token  = c.String("token")
+		server = resolveServer(c, ctx)
+		token  = resolveToken(c, ctx, creds, server)
 
 		client umschlag.ClientAPI
 	)
@@ -31,6 +84,19 @@ func Handle(c *cli.Context, fn HandleFunc) error {
 		os.Exit(1)
 	}
 
+	// Store the resolved values on the global flags, that way commands can
+	// access them without resolving the context again.
+	for _, l := range c.Lineage() {
+		if l.Set("server", server) == nil {
+			l.Set("token", token)
+			break
+		}
+	}
+
+	if ctx != nil && ctx.Output != "" {
+		applyOutput(c, ctx.Output)
+	}
+
 	if token == "" {
 		client = umschlag.NewClient(
 			server,
@@ -44,8 +110,97 @@ func Handle(c *cli.Context, fn HandleFunc) error {
 
 	if err := fn(c, client); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
-		os.Exit(2)
+		os.Exit(exitCode(err))
+	}
+
+	return nil
+}
+
+// HandleConfig wraps the command function handler for config commands.
+func HandleConfig(c *cli.Context, fn ConfigFunc) error {
+	cfg, err := LoadConfig(c)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := SetupColor(c, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := fn(c, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(exitCode(err))
 	}
 
 	return nil
 }
+
+// resolveServer picks the server address, the flag or environment variable
+// wins over the active context, which wins over the default.
+func resolveServer(c *cli.Context, ctx *ConfigContext) string {
+	if c.IsSet("server") {
+		return c.String("server")
+	}
+
+	if ctx != nil && ctx.Server != "" {
+		return ctx.Server
+	}
+
+	return defaultServer
+}
+
+// resolveToken picks the token, the flag or environment variable wins over
+// the active context, which wins over the credentials stored by login. The
+// token of the context is only used for the server of the context, that way
+// it never gets sent to a server passed by flag or environment variable.
+func resolveToken(c *cli.Context, ctx *ConfigContext, creds *Credentials, server string) string {
+	if c.IsSet("token") {
+		return c.String("token")
+	}
+
+	if ctx != nil && ctx.Token != "" && (!c.IsSet("server") || normalizeServer(server) == normalizeServer(ctx.Server)) {
+		return ctx.Token
+	}
+
+	if cred := creds.Get(server); cred != nil {
+		return cred.Token
+	}
+
+	return ""
+}
+
+// applyOutput sets the default output format of a context for commands
+// built with outputFlags where no format has been requested explicitly.
+func applyOutput(c *cli.Context, output string) {
+	if !hasOutputFlags(c) {
+		return
+	}
+
+	if c.IsSet("output") || c.IsSet("json") || c.IsSet("xml") || c.IsSet("format") {
+		return
+	}
+
+	c.Set("output", output)
+}
+
+// hasOutputFlags checks if the command has been built with outputFlags, the
+// query flag is only defined there. Other commands can define an output flag
+// with a different meaning.
+func hasOutputFlags(c *cli.Context) bool {
+	if c.Command == nil {
+		return false
+	}
+
+	for _, flag := range c.Command.Flags {
+		for _, name := range flag.Names() {
+			if name == "query" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
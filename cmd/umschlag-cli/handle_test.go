@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestResolveToken(t *testing.T) {
+	creds := &Credentials{
+		Servers: []*Credential{
+			{Server: "https://other.example.com", Token: "stored-token"},
+		},
+	}
+
+	ctx := &ConfigContext{
+		Name:   "prod",
+		Server: "https://prod.example.com",
+		Token:  "prod-token",
+	}
+
+	tests := []struct {
+		name   string
+		server string
+		token  string
+		ctx    *ConfigContext
+		expect string
+	}{
+		{name: "context", ctx: ctx, expect: "prod-token"},
+		{name: "flag token", token: "flag-token", ctx: ctx, expect: "flag-token"},
+		{name: "flag server with context", server: "https://other.example.com/", ctx: ctx, expect: "stored-token"},
+		{name: "unknown flag server with context", server: "https://evil.example.com", ctx: ctx, expect: ""},
+		{name: "flag server matching context", server: "https://prod.example.com/", ctx: ctx, expect: "prod-token"},
+		{name: "flag server without context", server: "https://other.example.com", expect: "stored-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestContext(t, "", "")
+			defer cleanup()
+
+			if tt.server != "" {
+				c.Set("server", tt.server)
+			}
+
+			if tt.token != "" {
+				c.Set("token", tt.token)
+			}
+
+			if got := resolveToken(c, tt.ctx, creds, resolveServer(c, tt.ctx)); got != tt.expect {
+				t.Errorf("expected token %q, got %q", tt.expect, got)
+			}
+		})
+	}
+}
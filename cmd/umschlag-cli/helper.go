@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -16,6 +18,12 @@ var sprigFuncMap = sprig.TxtFuncMap()
 
 // globalFuncMap provides global template helper functions.
 var globalFuncMap = template.FuncMap{
+	"highlight": highlight,
+	"success":   success,
+	"warning":   warning,
+	"danger":    danger,
+	"muted":     muted,
+	"bytes":     formatBytes,
 	"taglist": func(s []*umschlag.Tag) string {
 		res := []string{}
 
@@ -63,6 +71,66 @@ var globalFuncMap = template.FuncMap{
 	},
 }
 
+// formatBytes prints a size in bytes with binary units.
+func formatBytes(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	val := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+
+	for i, unit := range units {
+		val = val / 1024
+
+		if val < 1024 || i == len(units)-1 {
+			return fmt.Sprintf("%.1f %s", val, unit)
+		}
+	}
+
+	return ""
+}
+
+// identifierPattern matches numeric record IDs.
+var identifierPattern = regexp.MustCompile("^([0-9]+)$")
+
+// ResolveID resolves the id or slug of a registry, org or repo to the ID,
+// slugs get looked up on the server.
+func ResolveID(client umschlag.ClientAPI, kind, val string) (int64, error) {
+	if identifierPattern.MatchString(val) {
+		return strconv.ParseInt(val, 10, 64)
+	}
+
+	switch kind {
+	case "registry":
+		related, err := client.RegistryGet(val)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return related.ID, nil
+	case "org":
+		related, err := client.OrgGet(val)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return related.ID, nil
+	case "repo":
+		related, err := client.RepoGet(val)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return related.ID, nil
+	}
+
+	return 0, fmt.Errorf("unknown record kind %s", kind)
+}
+
 // GetIdentifierParam checks and returns the record id/slug parameter.
 func GetIdentifierParam(c *cli.Context) string {
 	val := c.String("id")
@@ -111,6 +179,20 @@ func GetOrgParam(c *cli.Context) string {
 	return val
 }
 
+// permissions defines the valid permissions for memberships.
+var permissions = []string{"user", "admin", "owner"}
+
+// validPerm checks if the permission is one of the valid permissions.
+func validPerm(val string) bool {
+	for _, perm := range permissions {
+		if perm == val {
+			return true
+		}
+	}
+
+	return false
+}
+
 // GetPermParam checks and returns the permission parameter.
 func GetPermParam(c *cli.Context) string {
 	val := c.String("perm")
@@ -120,10 +202,8 @@ func GetPermParam(c *cli.Context) string {
 		os.Exit(1)
 	}
 
-	for _, perm := range []string{"user", "admin", "owner"} {
-		if perm == val {
-			return val
-		}
+	if validPerm(val) {
+		return val
 	}
 
 	fmt.Println("error: invalid permission, can be user, admin or owner.")
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"github.com/umschlag/umschlag-cli/pkg/distribution"
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplTagInspect represents the manifest details of a tag.
+var tmplTagInspect = `Image: {{ highlight .Image }}{{ with .Platform }}
+Platform: {{ . }}{{ end }}
+Digest: {{ .Digest }}
+Media Type: {{ .MediaType }}
+Size: {{ bytes .Size }}{{ with .Layers }}
+Layers: {{ len . }}{{ end }}{{ with .Platforms }}
+Platforms: {{ join ", " . }}{{ end }}`
+
+// tableTagInspect defines the columns within the manifest details of a tag.
+var tableTagInspect = []outputColumn{
+	{Title: "IMAGE", Value: `{{ .Image }}`},
+	{Title: "PLATFORM", Value: `{{ .Platform }}`},
+	{Title: "DIGEST", Value: `{{ .Digest }}`},
+	{Title: "MEDIA TYPE", Value: `{{ .MediaType }}`},
+	{Title: "SIZE", Value: `{{ bytes .Size }}`},
+	{Title: "LAYERS", Value: `{{ len .Layers }}`},
+	{Title: "PLATFORMS", Value: `{{ join ", " .Platforms }}`},
+}
+
+// tmplTagPlatforms represents a row within the platforms of a tag.
+var tmplTagPlatforms = `Platform: {{ highlight .Platform }}
+Digest: {{ .Digest }}
+Size: {{ bytes .Size }}
+`
+
+// tableTagPlatforms defines the columns within the platforms of a tag.
+var tableTagPlatforms = []outputColumn{
+	{Title: "PLATFORM", Value: `{{ .Platform }}`},
+	{Title: "DIGEST", Value: `{{ .Digest }}`},
+	{Title: "SIZE", Value: `{{ bytes .Size }}`},
+	{Title: "MEDIA TYPE", Value: `{{ .MediaType }}`, Wide: true},
+}
+
+// imageRef represents a tag resolved to its location within a registry.
+type imageRef struct {
+	Registry   *umschlag.Registry
+	Repository string
+	Tag        *umschlag.Tag
+}
+
+// String formats the reference like docker does it.
+func (r *imageRef) String() string {
+	return normalizeHost(r.Registry.Host) + "/" + r.Repository + ":" + r.Tag.Name
+}
+
+// tagInspectRecord represents the manifest details of a tag for output.
+type tagInspectRecord struct {
+	XMLName   xml.Name                  `json:"-" xml:"image"`
+	Image     string                    `json:"image" xml:"name"`
+	Platform  string                    `json:"platform,omitempty" xml:"platform,omitempty"`
+	Digest    string                    `json:"digest" xml:"digest"`
+	MediaType string                    `json:"media_type" xml:"media-type"`
+	Size      int64                     `json:"size" xml:"size"`
+	Config    *distribution.Descriptor  `json:"config,omitempty" xml:"config,omitempty"`
+	Layers    []distribution.Descriptor `json:"layers,omitempty" xml:"layers>layer,omitempty"`
+	Manifests []distribution.Descriptor `json:"manifests,omitempty" xml:"manifests>manifest,omitempty"`
+	Platforms []string                  `json:"platforms,omitempty" xml:"platforms>platform,omitempty"`
+}
+
+// tagPlatformRecord represents a single platform of a tag for output.
+type tagPlatformRecord struct {
+	XMLName   xml.Name `json:"-" xml:"platform"`
+	Platform  string   `json:"platform" xml:"name"`
+	Digest    string   `json:"digest" xml:"digest"`
+	MediaType string   `json:"media_type" xml:"media-type"`
+	Size      int64    `json:"size" xml:"size"`
+}
+
+// resolveTagImage follows the tag to its repo, org and registry.
+func resolveTagImage(client umschlag.ClientAPI, id string) (*imageRef, error) {
+	tag, err := client.TagGet(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := client.RepoGet(
+		strconv.FormatInt(tag.RepoID, 10),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := client.OrgGet(
+		strconv.FormatInt(repo.OrgID, 10),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	registry, err := client.RegistryGet(
+		strconv.FormatInt(org.RegistryID, 10),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &imageRef{
+		Registry:   registry,
+		Repository: repo.FullName,
+		Tag:        tag,
+	}, nil
+}
+
+// newRegistryClient creates a registry client authenticated with the
+// credentials derived from the Umschlag token.
+func newRegistryClient(c *cli.Context, client umschlag.ClientAPI, registry *umschlag.Registry) (*distribution.Client, error) {
+	if c.String("token") == "" {
+		return distribution.NewClient(registry.Host, "", ""), nil
+	}
+
+	username, secret, err := registryCredentials(c, client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return distribution.NewClient(registry.Host, username, secret), nil
+}
+
+// TagInspect provides the sub-command to show the manifest of a tag.
+func TagInspect(c *cli.Context, client umschlag.ClientAPI) error {
+	ref, err := resolveTagImage(
+		client,
+		GetIdentifierParam(c),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	registry, err := newRegistryClient(c, client, ref.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	manifest, err := registry.Manifest(ref.Repository, ref.Tag.Name)
+
+	if err != nil {
+		return err
+	}
+
+	platform := ""
+
+	if val := c.String("platform"); val != "" {
+		selector, err := distribution.ParsePlatform(val)
+
+		if err != nil {
+			return err
+		}
+
+		if manifest, platform, err = selectPlatform(registry, ref.Repository, manifest, selector); err != nil {
+			return err
+		}
+	}
+
+	size, err := registry.Size(ref.Repository, manifest)
+
+	if err != nil {
+		return err
+	}
+
+	record := &tagInspectRecord{
+		Image:     ref.String(),
+		Platform:  platform,
+		Digest:    manifest.Digest,
+		MediaType: manifest.MediaType,
+		Size:      size,
+		Config:    manifest.Config,
+		Layers:    manifest.Layers,
+		Manifests: manifest.Manifests,
+	}
+
+	for _, child := range manifest.Manifests {
+		if child.Platform != nil {
+			record.Platforms = append(record.Platforms, child.Platform.String())
+		}
+	}
+
+	return RenderRecord(c, record, tableTagInspect)
+}
+
+// TagPlatforms provides the sub-command to show the platforms of a tag.
+func TagPlatforms(c *cli.Context, client umschlag.ClientAPI) error {
+	var selector *distribution.Platform
+
+	if val := c.String("platform"); val != "" {
+		parsed, err := distribution.ParsePlatform(val)
+
+		if err != nil {
+			return err
+		}
+
+		selector = parsed
+	}
+
+	ref, err := resolveTagImage(
+		client,
+		GetIdentifierParam(c),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	registry, err := newRegistryClient(c, client, ref.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	manifest, err := registry.Manifest(ref.Repository, ref.Tag.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if selector != nil {
+		selected, platform, err := selectPlatform(registry, ref.Repository, manifest, selector)
+
+		if err != nil {
+			return err
+		}
+
+		return RenderList(c, []*tagPlatformRecord{{
+			Platform:  platform,
+			Digest:    selected.Digest,
+			MediaType: selected.MediaType,
+			Size:      selected.TotalSize(),
+		}}, tableTagPlatforms)
+	}
+
+	records, err := imagePlatforms(registry, ref.Repository, manifest)
+
+	if err != nil {
+		return err
+	}
+
+	return RenderList(c, records, tableTagPlatforms)
+}
+
+// imagePlatforms expands a manifest list into the platform manifests, a
+// single manifest gets its platform from the image config.
+func imagePlatforms(registry *distribution.Client, repo string, manifest *distribution.Manifest) ([]*tagPlatformRecord, error) {
+	if !manifest.IsList() {
+		config, err := registry.Config(repo, manifest)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []*tagPlatformRecord{{
+			Platform:  config.Platform().String(),
+			Digest:    manifest.Digest,
+			MediaType: manifest.MediaType,
+			Size:      manifest.TotalSize(),
+		}}, nil
+	}
+
+	records := make([]*tagPlatformRecord, 0, len(manifest.Manifests))
+
+	for _, child := range manifest.Manifests {
+		if child.Platform == nil {
+			continue
+		}
+
+		image, err := registry.Manifest(repo, child.Digest)
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, &tagPlatformRecord{
+			Platform:  child.Platform.String(),
+			Digest:    child.Digest,
+			MediaType: child.MediaType,
+			Size:      image.TotalSize(),
+		})
+	}
+
+	return records, nil
+}
+
+// selectPlatform resolves the platform specific manifest, a single manifest
+// is returned if its image config matches the selector.
+func selectPlatform(registry *distribution.Client, repo string, manifest *distribution.Manifest, selector *distribution.Platform) (*distribution.Manifest, string, error) {
+	if !manifest.IsList() {
+		config, err := registry.Config(repo, manifest)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if !config.Platform().Match(selector) {
+			return nil, "", fmt.Errorf("no manifest available for platform %s", selector)
+		}
+
+		return manifest, config.Platform().String(), nil
+	}
+
+	child, err := manifest.Select(selector)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := registry.Manifest(repo, child.Digest)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result, child.Platform.String(), nil
+}
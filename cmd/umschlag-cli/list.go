@@ -0,0 +1,428 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// semverSort defines the sort key to order records by the name as semver.
+const semverSort = "semver"
+
+// versionType is used to detect parsed semver versions.
+var versionType = reflect.TypeOf(&semver.Version{})
+
+// timeType is used to detect timestamps within the records.
+var timeType = reflect.TypeOf(time.Time{})
+
+// listFlags returns the flags to filter, sort and limit a list of records
+// together with the output flags.
+func listFlags(tmpl string) []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "Filter records by field=value or field=~regex, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Value: "",
+			Usage: "Sort records by field[:desc] or semver[:desc], multiple fields separated by comma",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Value: 0,
+			Usage: "Limit the number of records",
+		},
+	}, outputFlags(tmpl)...)
+}
+
+// listFilter represents a single filter condition on a field.
+type listFilter struct {
+	Field string
+	Value string
+	Regex *regexp.Regexp
+}
+
+// listSort represents a single sort key on a field.
+type listSort struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions defines the filters, sort keys and limit for list commands.
+type ListOptions struct {
+	Filters []listFilter
+	Sorts   []listSort
+	Limit   int
+}
+
+// ParseListOptions parses the list flags of the command.
+func ParseListOptions(c *cli.Context) (*ListOptions, error) {
+	opts := &ListOptions{
+		Limit: c.Int("limit"),
+	}
+
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	for _, raw := range c.StringSlice("filter") {
+		idx := strings.Index(raw, "=")
+
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid filter %s, expected field=value or field=~regex", raw)
+		}
+
+		filter := listFilter{
+			Field: strings.TrimSpace(raw[:idx]),
+			Value: raw[idx+1:],
+		}
+
+		if strings.HasPrefix(filter.Value, "~") {
+			regex, err := regexp.Compile(filter.Value[1:])
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %s: %s", raw, err)
+			}
+
+			filter.Regex = regex
+		}
+
+		opts.Filters = append(opts.Filters, filter)
+	}
+
+	if raw := c.String("sort"); raw != "" {
+		for _, key := range strings.Split(raw, ",") {
+			parts := strings.SplitN(strings.TrimSpace(key), ":", 2)
+
+			sorter := listSort{
+				Field: parts[0],
+			}
+
+			if len(parts) == 2 {
+				switch strings.ToLower(parts[1]) {
+				case "asc":
+					sorter.Desc = false
+				case "desc":
+					sorter.Desc = true
+				default:
+					return nil, fmt.Errorf("invalid sort order %s, can be asc or desc", parts[1])
+				}
+			}
+
+			if sorter.Field == "" {
+				return nil, fmt.Errorf("invalid sort %s, expected field[:desc]", raw)
+			}
+
+			opts.Sorts = append(opts.Sorts, sorter)
+		}
+	}
+
+	return opts, nil
+}
+
+// Apply filters, sorts and limits the records, which have to be a slice of
+// structs or struct pointers. The result keeps the type of the records.
+func (o *ListOptions) Apply(records interface{}) (interface{}, error) {
+	if len(o.Filters) == 0 && len(o.Sorts) == 0 && o.Limit == 0 {
+		return records, nil
+	}
+
+	val := reflect.ValueOf(records)
+
+	if val.Kind() != reflect.Slice {
+		return records, nil
+	}
+
+	filters := make([][]int, len(o.Filters))
+
+	for i, filter := range o.Filters {
+		path, err := fieldPath(val.Type().Elem(), filter.Field)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters[i] = path
+	}
+
+	sorts := make([][]int, len(o.Sorts))
+
+	for i, sorter := range o.Sorts {
+		field := sorter.Field
+
+		if strings.EqualFold(field, semverSort) {
+			field = "name"
+		}
+
+		path, err := fieldPath(val.Type().Elem(), field)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sorts[i] = path
+	}
+
+	items := make([]reflect.Value, 0, val.Len())
+
+	for i := 0; i < val.Len(); i++ {
+		if o.matches(val.Index(i), filters) {
+			items = append(items, val.Index(i))
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for k, sorter := range o.Sorts {
+			a := fieldValue(items[i], sorts[k])
+			b := fieldValue(items[j], sorts[k])
+
+			if strings.EqualFold(sorter.Field, semverSort) {
+				a = semverValue(a)
+				b = semverValue(b)
+			}
+
+			switch {
+			case !a.IsValid() && !b.IsValid():
+				continue
+			case !a.IsValid():
+				return false
+			case !b.IsValid():
+				return true
+			}
+
+			cmp := compareField(a, b)
+
+			if sorter.Desc {
+				cmp = -cmp
+			}
+
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+
+		return false
+	})
+
+	if o.Limit > 0 && len(items) > o.Limit {
+		items = items[:o.Limit]
+	}
+
+	result := reflect.MakeSlice(val.Type(), 0, len(items))
+
+	for _, item := range items {
+		result = reflect.Append(result, item)
+	}
+
+	return result.Interface(), nil
+}
+
+// matches checks if the record passes all filters.
+func (o *ListOptions) matches(item reflect.Value, paths [][]int) bool {
+	for i, filter := range o.Filters {
+		val := fieldString(fieldValue(item, paths[i]))
+
+		if filter.Regex != nil {
+			if !filter.Regex.MatchString(val) {
+				return false
+			}
+
+			continue
+		}
+
+		if !strings.EqualFold(val, filter.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyListOptions applies the list flags if they are defined for the command.
+func applyListOptions(c *cli.Context, records interface{}) (interface{}, error) {
+	opts, err := ParseListOptions(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return opts.Apply(records)
+}
+
+// fieldPath resolves a dotted field name to the struct field indices, the
+// names are matched like within queries, ignoring casing and underscores.
+func fieldPath(typ reflect.Type, name string) ([]int, error) {
+	path := []int{}
+
+	for _, part := range strings.Split(name, ".") {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		if typ.Kind() != reflect.Struct || typ == timeType {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+
+		found := false
+
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+
+			if field.PkgPath != "" {
+				continue
+			}
+
+			tag := strings.Split(field.Tag.Get("json"), ",")[0]
+
+			if normalizeField(field.Name) == normalizeField(part) || (tag != "" && normalizeField(tag) == normalizeField(part)) {
+				path = append(path, i)
+				typ = field.Type
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+	}
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return path, nil
+	}
+
+	if typ == timeType {
+		return path, nil
+	}
+
+	return nil, fmt.Errorf("field %s can not be used to filter or sort", name)
+}
+
+// fieldValue walks the field indices, it returns an invalid value if any
+// pointer on the way is nil.
+func fieldValue(val reflect.Value, path []int) reflect.Value {
+	for _, idx := range path {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return reflect.Value{}
+			}
+
+			val = val.Elem()
+		}
+
+		val = val.Field(idx)
+	}
+
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+
+		val = val.Elem()
+	}
+
+	return val
+}
+
+// fieldString formats a field value for filtering, timestamps use RFC3339.
+func fieldString(val reflect.Value) string {
+	if !val.IsValid() {
+		return ""
+	}
+
+	if val.Type() == timeType {
+		return val.Interface().(time.Time).Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(val.Interface())
+}
+
+// semverValue parses the value as semver, it returns an invalid value for
+// anything else to sort these records last.
+func semverValue(val reflect.Value) reflect.Value {
+	if !val.IsValid() {
+		return val
+	}
+
+	version, err := semver.NewVersion(fmt.Sprint(val.Interface()))
+
+	if err != nil {
+		return reflect.Value{}
+	}
+
+	return reflect.ValueOf(version)
+}
+
+// compareField compares two values of the same field type.
+func compareField(a, b reflect.Value) int {
+	if a.Type() == versionType {
+		return a.Interface().(*semver.Version).Compare(b.Interface().(*semver.Version))
+	}
+
+	if a.Type() == timeType {
+		x := a.Interface().(time.Time)
+		y := b.Interface().(time.Time)
+
+		switch {
+		case x.Before(y):
+			return -1
+		case x.After(y):
+			return 1
+		}
+
+		return 0
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String()))
+	case reflect.Bool:
+		switch {
+		case a.Bool() == b.Bool():
+			return 0
+		case b.Bool():
+			return -1
+		}
+
+		return 1
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case a.Int() < b.Int():
+			return -1
+		case a.Int() > b.Int():
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case a.Uint() < b.Uint():
+			return -1
+		case a.Uint() > b.Uint():
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case a.Float() < b.Float():
+			return -1
+		case a.Float() > b.Float():
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logger writes structured log lines in logfmt format, it gets used by the
+// long running commands.
+type logger struct {
+	mutex sync.Mutex
+	out   io.Writer
+}
+
+// newLogger initializes a logger writing to out.
+func newLogger(out io.Writer) *logger {
+	return &logger{
+		out: out,
+	}
+}
+
+// Info logs a message with additional key value pairs.
+func (l *logger) Info(msg string, keyvals ...interface{}) {
+	l.log("info", msg, keyvals...)
+}
+
+// Warn logs a warning with additional key value pairs.
+func (l *logger) Warn(msg string, keyvals ...interface{}) {
+	l.log("warn", msg, keyvals...)
+}
+
+// Error logs an error with additional key value pairs.
+func (l *logger) Error(msg string, keyvals ...interface{}) {
+	l.log("error", msg, keyvals...)
+}
+
+// log formats a single line, keys without a value get an empty value.
+func (l *logger) log(level, msg string, keyvals ...interface{}) {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "time=%s level=%s msg=%s", time.Now().Format(time.RFC3339), level, logValue(msg))
+
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = ""
+
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+
+		fmt.Fprintf(buf, " %v=%s", keyvals[i], logValue(val))
+	}
+
+	buf.WriteString("\n")
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.out.Write(buf.Bytes())
+}
+
+// logValue formats a value and quotes it if required.
+func logValue(val interface{}) string {
+	var res string
+
+	switch v := val.(type) {
+	case error:
+		res = v.Error()
+	case time.Duration:
+		res = v.String()
+	case time.Time:
+		res = v.Format(time.RFC3339)
+	default:
+		res = fmt.Sprintf("%v", v)
+	}
+
+	if res == "" || strings.ContainsAny(res, " =\"\t\n") {
+		return strconv.Quote(res)
+	}
+
+	return res
+}
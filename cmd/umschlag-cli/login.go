@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// Login provides the sub-command to store credentials.
+func Login() *cli.Command {
+	return &cli.Command{
+		Name:      "login",
+		Usage:     "Login and store credentials",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "username",
+				Value: "",
+				Usage: "Username for authentication",
+			},
+			&cli.BoolFlag{
+				Name:  "password-stdin",
+				Value: false,
+				Usage: "Read the password from stdin",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return Handle(c, LoginAction)
+		},
+	}
+}
+
+// Logout provides the sub-command to delete stored credentials.
+func Logout() *cli.Command {
+	return &cli.Command{
+		Name:      "logout",
+		Usage:     "Delete stored credentials",
+		ArgsUsage: " ",
+		Action: func(c *cli.Context) error {
+			return Handle(c, LogoutAction)
+		},
+	}
+}
+
+// LoginAction exchanges username and password for a token and stores it.
+func LoginAction(c *cli.Context, client umschlag.ClientAPI) error {
+	creds, err := LoadCredentials(c)
+
+	if err != nil {
+		return err
+	}
+
+	username := c.String("username")
+
+	if username == "" {
+		if c.Bool("password-stdin") {
+			return fmt.Errorf("you must provide a username with --password-stdin")
+		}
+
+		if username, err = promptUsername(); err != nil {
+			return err
+		}
+	}
+
+	if username == "" {
+		return fmt.Errorf("you must provide a username")
+	}
+
+	password, err := readPassword(c.Bool("password-stdin"))
+
+	if err != nil {
+		return err
+	}
+
+	if password == "" {
+		return fmt.Errorf("you must provide a password")
+	}
+
+	login, err := umschlag.NewClient(
+		c.String("server"),
+	).AuthLogin(
+		username,
+		password,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	record, err := umschlag.NewClientToken(
+		c.String("server"),
+		login.Token,
+	).ProfileToken()
+
+	if err != nil {
+		return err
+	}
+
+	creds.Set(
+		c.String("server"),
+		username,
+		record.Token,
+	)
+
+	if err := creds.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully logged in to %s\n", c.String("server"))
+	return nil
+}
+
+// LogoutAction deletes the stored token for the server.
+func LogoutAction(c *cli.Context, client umschlag.ClientAPI) error {
+	creds, err := LoadCredentials(c)
+
+	if err != nil {
+		return err
+	}
+
+	if !creds.Remove(c.String("server")) {
+		return fmt.Errorf("not logged in to %s", c.String("server"))
+	}
+
+	if err := creds.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully logged out from %s\n", c.String("server"))
+	return nil
+}
+
+// promptUsername asks for the username on the terminal.
+func promptUsername() (string, error) {
+	fmt.Fprintf(os.Stderr, "Username: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// readPassword reads the password from stdin or prompts for it without
+// echoing the input.
+func readPassword(stdin bool) (string, error) {
+	if stdin {
+		content, err := ioutil.ReadAll(os.Stdin)
+
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("cannot prompt for a password, use --password-stdin")
+	}
+
+	fmt.Fprintf(os.Stderr, "Password: ")
+	defer fmt.Fprintf(os.Stderr, "\n")
+
+	content, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
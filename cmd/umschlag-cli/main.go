@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,6 +16,12 @@ func main() {
 		godotenv.Load(env)
 	}
 
+	args := os.Args
+
+	if name := filepath.Base(args[0]); strings.TrimSuffix(name, filepath.Ext(name)) == dockerCredentialHelper {
+		args = append([]string{args[0], "credential-helper"}, args[1:]...)
+	}
+
 	app := &cli.App{
 		Name:     "umschlag-cli",
 		Version:  version.String,
@@ -30,7 +38,7 @@ func main() {
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "server, s",
-				Value:   "http://localhost:8080",
+				Value:   defaultServer,
 				Usage:   "api server",
 				EnvVars: []string{"UMSCHLAG_SERVER"},
 			},
@@ -40,9 +48,30 @@ func main() {
 				Usage:   "api token",
 				EnvVars: []string{"UMSCHLAG_TOKEN"},
 			},
+			&cli.StringFlag{
+				Name:    "config",
+				Value:   defaultConfigPath(),
+				Usage:   "path to config file",
+				EnvVars: []string{"UMSCHLAG_CONFIG"},
+			},
+			&cli.StringFlag{
+				Name:    "context",
+				Value:   "",
+				Usage:   "context to use instead of the current one",
+				EnvVars: []string{"UMSCHLAG_CONTEXT"},
+			},
+			&cli.StringFlag{
+				Name:    "color",
+				Value:   "auto",
+				Usage:   "colored output, can be " + strings.Join(colorModes, ", "),
+				EnvVars: []string{"UMSCHLAG_COLOR"},
+			},
 		},
 
 		Commands: []*cli.Command{
+			Context(),
+			Login(),
+			Logout(),
 			Profile(),
 			Registry(),
 			Tag(),
@@ -50,6 +79,15 @@ func main() {
 			Org(),
 			User(),
 			Team(),
+			Pin(),
+			Apply(),
+			Export(),
+			Import(),
+			Migrate(),
+			Access(),
+			Notifications(),
+			Daemon(),
+			CredentialHelper(),
 		},
 	}
 
@@ -65,7 +103,7 @@ func main() {
 		Usage:   "print the current version of that tool",
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	if err := app.Run(args); err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// Migrate provides the sub-command to copy records between servers.
+func Migrate() *cli.Command {
+	return &cli.Command{
+		Name:      "migrate",
+		Usage:     "Copy orgs with registries, teams and users to another server",
+		ArgsUsage: " ",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "from-context",
+				Value: "",
+				Usage: "Context of the server to copy from",
+			},
+			&cli.StringFlag{
+				Name:  "to-context",
+				Value: "",
+				Usage: "Context of the server to copy to",
+			},
+			&cli.StringSliceFlag{
+				Name:  "org",
+				Usage: "Slug of an org to copy, can be repeated, defaults to all",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-users",
+				Value: false,
+				Usage: "Skip users missing on the target instead of using temporary passwords",
+			},
+			&cli.BoolFlag{
+				Name:  "overwrite",
+				Value: false,
+				Usage: "Update existing records which differ from the source",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Value: false,
+				Usage: "Only report what would be copied",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Value: "",
+				Usage: "Write the report including temporary passwords to this path, the format depends on the extension",
+			},
+		}, outputFlags(tmplImport)...),
+		Action: func(c *cli.Context) error {
+			return HandleConfig(c, MigrateAction)
+		},
+	}
+}
+
+// MigrateAction copies the selected orgs from one server to another. Records
+// are matched by slug or username, passwords are never copied.
+func MigrateAction(c *cli.Context, cfg *Config) error {
+	from, to := c.String("from-context"), c.String("to-context")
+
+	if from == "" || to == "" {
+		return fmt.Errorf("you must provide the from and to context")
+	}
+
+	if from == to {
+		return fmt.Errorf("from and to context must differ")
+	}
+
+	creds, err := LoadCredentials(c)
+
+	if err != nil {
+		return err
+	}
+
+	source, sourceCtx, err := contextClient(cfg, creds, from)
+
+	if err != nil {
+		return err
+	}
+
+	target, _, err := contextClient(cfg, creds, to)
+
+	if err != nil {
+		return err
+	}
+
+	live, err := fetchLiveState(source)
+
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %s", from, err)
+	}
+
+	export, err := buildExport(live, sourceCtx.Server)
+
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %s", from, err)
+	}
+
+	doc, err := selectOrgs(
+		export,
+		c.StringSlice("org"),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"Copying %d registries, %d orgs, %d teams and %d users from %s to %s\n",
+		len(doc.Registries),
+		len(doc.Orgs),
+		len(doc.Teams),
+		len(doc.Users),
+		from,
+		to,
+	)
+
+	report, err := importBackup(target, doc, ImportOptions{
+		DryRun:          c.Bool("dry-run"),
+		Overwrite:       c.Bool("overwrite"),
+		SkipUsers:       c.Bool("skip-users"),
+		ReportPasswords: c.String("report") != "",
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to migrate to %s: %s", to, err)
+	}
+
+	return finishImport(c, "migration", report)
+}
+
+// contextClient creates a client for the named context, the token falls back
+// to the credentials stored by login.
+func contextClient(cfg *Config, creds *Credentials, name string) (umschlag.ClientAPI, *ConfigContext, error) {
+	ctx := cfg.Context(name)
+
+	if ctx == nil {
+		return nil, nil, fmt.Errorf("context %s is not defined", name)
+	}
+
+	token := ctx.Token
+
+	if cred := creds.Get(ctx.Server); token == "" && cred != nil {
+		token = cred.Token
+	}
+
+	if token == "" {
+		return umschlag.NewClient(ctx.Server), ctx, nil
+	}
+
+	return umschlag.NewClientToken(ctx.Server, token), ctx, nil
+}
+
+// selectOrgs reduces the document to the given orgs together with their
+// registries, the teams and users with access to them and their memberships
+// within these orgs. Without any org the document is kept as it is.
+func selectOrgs(doc *Backup, slugs []string) (*Backup, error) {
+	if len(slugs) == 0 {
+		return doc, nil
+	}
+
+	selected := map[string]bool{}
+
+	for _, slug := range slugs {
+		selected[slug] = true
+	}
+
+	var (
+		orgs       = []*StateOrg{}
+		registries = map[string]bool{}
+	)
+
+	for _, org := range doc.Orgs {
+		if !selected[org.Slug] {
+			continue
+		}
+
+		orgs = append(orgs, org)
+		registries[org.Registry] = true
+		delete(selected, org.Slug)
+	}
+
+	for _, slug := range slugs {
+		if selected[slug] {
+			return nil, fmt.Errorf("org %s doesn't exist", slug)
+		}
+
+		selected[slug] = true
+	}
+
+	result := &Backup{
+		Version:    doc.Version,
+		ExportedAt: doc.ExportedAt,
+		Server:     doc.Server,
+	}
+
+	result.Orgs = orgs
+
+	for _, registry := range doc.Registries {
+		if registries[registry.Slug] {
+			result.Registries = append(result.Registries, registry)
+		}
+	}
+
+	members := map[string]bool{}
+
+	for _, team := range doc.Teams {
+		team.Orgs = filterMembers(team.Orgs, selected)
+
+		if len(team.Orgs) == 0 {
+			continue
+		}
+
+		for username := range team.Users {
+			members[username] = true
+		}
+
+		result.Teams = append(result.Teams, team)
+	}
+
+	for _, user := range doc.Users {
+		user.Orgs = filterMembers(user.Orgs, selected)
+
+		if len(user.Orgs) == 0 && !members[user.Username] {
+			continue
+		}
+
+		result.Users = append(result.Users, user)
+	}
+
+	return result, nil
+}
+
+// filterMembers keeps only the memberships of the selected orgs.
+func filterMembers(members map[string]string, selected map[string]bool) map[string]string {
+	result := map[string]string{}
+
+	for slug, perm := range members {
+		if selected[slug] {
+			result[slug] = perm
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// notificationsMaxBody defines the maximum size of a notification envelope.
+const notificationsMaxBody = 10 * 1024 * 1024
+
+// notificationEnvelope represents the envelope sent by docker distribution.
+type notificationEnvelope struct {
+	Events []*notificationEvent `json:"events"`
+}
+
+// notificationEvent represents a single event within the envelope.
+type notificationEvent struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Target    struct {
+		MediaType  string `json:"mediaType"`
+		Digest     string `json:"digest"`
+		Repository string `json:"repository"`
+		URL        string `json:"url"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+	Request struct {
+		ID   string `json:"id"`
+		Addr string `json:"addr"`
+		Host string `json:"host"`
+	} `json:"request"`
+}
+
+// host returns the registry host the event has been triggered for.
+func (e *notificationEvent) host() string {
+	if e.Request.Host != "" {
+		return e.Request.Host
+	}
+
+	if u, err := url.Parse(e.Target.URL); err == nil {
+		return u.Host
+	}
+
+	return ""
+}
+
+// syncDebouncer collects events per registry and triggers a single sync once
+// no further events arrived within the delay.
+type syncDebouncer struct {
+	mutex   sync.Mutex
+	wg      sync.WaitGroup
+	delay   time.Duration
+	timers  map[int64]*time.Timer
+	pending map[int64]*umschlag.Registry
+	sync    func(*umschlag.Registry)
+	stopped bool
+}
+
+// newSyncDebouncer initializes a debouncer calling sync after the delay.
+func newSyncDebouncer(delay time.Duration, sync func(*umschlag.Registry)) *syncDebouncer {
+	return &syncDebouncer{
+		delay:   delay,
+		timers:  map[int64]*time.Timer{},
+		pending: map[int64]*umschlag.Registry{},
+		sync:    sync,
+	}
+}
+
+// Trigger schedules a sync for the registry or postpones a scheduled one,
+// events arriving after the flush are dropped.
+func (d *syncDebouncer) Trigger(registry *umschlag.Registry) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	d.pending[registry.ID] = registry
+
+	if timer, ok := d.timers[registry.ID]; ok && timer.Stop() {
+		timer.Reset(d.delay)
+		return
+	}
+
+	var timer *time.Timer
+
+	timer = time.AfterFunc(d.delay, func() {
+		d.mutex.Lock()
+
+		if d.stopped || d.timers[registry.ID] != timer {
+			d.mutex.Unlock()
+			return
+		}
+
+		record := d.pending[registry.ID]
+
+		delete(d.pending, registry.ID)
+		delete(d.timers, registry.ID)
+
+		d.wg.Add(1)
+		d.mutex.Unlock()
+
+		defer d.wg.Done()
+		d.sync(record)
+	})
+
+	d.timers[registry.ID] = timer
+}
+
+// Flush triggers all scheduled syncs right away and waits for running syncs,
+// afterwards no further syncs get scheduled.
+func (d *syncDebouncer) Flush() {
+	d.mutex.Lock()
+
+	d.stopped = true
+
+	records := []*umschlag.Registry{}
+
+	for id, timer := range d.timers {
+		timer.Stop()
+		records = append(records, d.pending[id])
+	}
+
+	d.timers = map[int64]*time.Timer{}
+	d.pending = map[int64]*umschlag.Registry{}
+
+	d.mutex.Unlock()
+
+	for _, record := range records {
+		d.sync(record)
+	}
+
+	d.wg.Wait()
+}
+
+// notificationHandler receives the notifications of docker distribution.
+type notificationHandler struct {
+	client    umschlag.ClientAPI
+	log       *logger
+	header    string
+	secret    string
+	debouncer *syncDebouncer
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *notificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(h.header)), []byte(h.secret)) != 1 {
+		h.log.Warn("rejected notification", "remote", r.RemoteAddr, "reason", "invalid secret")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	envelope := &notificationEnvelope{}
+
+	if err := json.NewDecoder(io.LimitReader(r.Body, notificationsMaxBody)).Decode(envelope); err != nil {
+		h.log.Warn("rejected notification", "remote", r.RemoteAddr, "reason", err)
+		http.Error(w, "invalid envelope", http.StatusBadRequest)
+		return
+	}
+
+	registries, err := h.client.RegistryList()
+
+	if err != nil {
+		h.log.Error("failed to list registries", "err", err)
+		http.Error(w, "failed to list registries", http.StatusBadGateway)
+		return
+	}
+
+	for _, event := range envelope.Events {
+		if event.Action != "push" && event.Action != "delete" {
+			continue
+		}
+
+		host := event.host()
+		var registry *umschlag.Registry
+
+		for _, record := range registries {
+			if normalizeHost(record.Host) == normalizeHost(host) {
+				registry = record
+				break
+			}
+		}
+
+		if registry == nil {
+			h.log.Warn("ignored event", "id", event.ID, "action", event.Action, "host", host, "reason", "unknown registry")
+			continue
+		}
+
+		h.log.Info(
+			"received event",
+			"id", event.ID,
+			"action", event.Action,
+			"registry", registry.Slug,
+			"repository", event.Target.Repository,
+			"tag", event.Target.Tag,
+			"digest", event.Target.Digest,
+		)
+
+		h.debouncer.Trigger(registry)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Notifications provides the sub-command for registry notifications.
+func Notifications() *cli.Command {
+	return &cli.Command{
+		Name:  "notifications",
+		Usage: "Registry notification related sub-commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "serve",
+				Usage:     "Receive registry notifications and trigger syncs",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "listen",
+						Value:   ":9000",
+						Usage:   "Address to listen on",
+						EnvVars: []string{"UMSCHLAG_NOTIFICATIONS_LISTEN"},
+					},
+					&cli.StringFlag{
+						Name:    "secret",
+						Value:   "",
+						Usage:   "Shared secret the registry has to send",
+						EnvVars: []string{"UMSCHLAG_NOTIFICATIONS_SECRET"},
+					},
+					&cli.StringFlag{
+						Name:  "secret-header",
+						Value: "X-Umschlag-Secret",
+						Usage: "Header which contains the shared secret",
+					},
+					&cli.DurationFlag{
+						Name:  "debounce",
+						Value: 30 * time.Second,
+						Usage: "Time to wait for further events before a sync",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return Handle(c, NotificationsServe)
+				},
+			},
+		},
+	}
+}
+
+// NotificationsServe provides the sub-command to receive notifications.
+func NotificationsServe(c *cli.Context, client umschlag.ClientAPI) error {
+	log := newLogger(os.Stderr)
+
+	if c.String("secret") == "" {
+		log.Warn("no secret configured, notifications are not authenticated")
+	}
+
+	debouncer := newSyncDebouncer(
+		c.Duration("debounce"),
+		func(registry *umschlag.Registry) {
+			if err := client.RegistrySync(strconv.FormatInt(registry.ID, 10)); err != nil {
+				log.Error("failed to trigger sync", "registry", registry.Slug, "err", err)
+				return
+			}
+
+			log.Info("triggered sync", "registry", registry.Slug)
+		},
+	)
+
+	mux := http.NewServeMux()
+
+	mux.Handle("/events", &notificationHandler{
+		client:    client,
+		log:       log,
+		header:    c.String("secret-header"),
+		secret:    c.String("secret"),
+		debouncer: debouncer,
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, "ok")
+	})
+
+	server := &http.Server{
+		Addr:              c.String("listen"),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		log.Info("starting notification listener", "addr", server.Addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		log.Info("shutting down", "signal", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("failed to shutdown gracefully", "err", err)
+	}
+
+	debouncer.Flush()
+	log.Info("stopped notification listener")
+
+	return nil
+}
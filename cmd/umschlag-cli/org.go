@@ -1,26 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"regexp"
-	"strconv"
-	"text/template"
 
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplOrgList represents a row within org listing.
-var tmplOrgList = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplOrgList = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .Name }}
 `
 
 // tmplOrgShow represents a org within details view.
-var tmplOrgShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplOrgShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .Name }}{{with .Registry}}
 Registry: {{ .Name }}{{end}}{{with .Repos}}
@@ -32,19 +27,58 @@ Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
 // tmplOrgUserList represents a row within org user listing.
-var tmplOrgUserList = "Slug: \x1b[33m{{ .User.Slug }} \x1b[0m" + `
+var tmplOrgUserList = `Slug: {{ highlight .User.Slug }}
 ID: {{ .User.ID }}
 Username: {{ .User.Username }}
 Permission: {{ .Perm }}
 `
 
 // tmplOrgTeamList represents a row within org team listing.
-var tmplOrgTeamList = "Slug: \x1b[33m{{ .Team.Slug }} \x1b[0m" + `
+var tmplOrgTeamList = `Slug: {{ highlight .Team.Slug }}
 ID: {{ .Team.ID }}
 Name: {{ .Team.Name }}
 Permission: {{ .Perm }}
 `
 
+// tableOrgList defines the columns within org listing.
+var tableOrgList = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "REGISTRY", Value: `{{ with .Registry }}{{ .Name }}{{ end }}`, Wide: true},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+}
+
+// tableOrgShow defines the columns within org details view.
+var tableOrgShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "REGISTRY", Value: `{{ with .Registry }}{{ .Name }}{{ end }}`},
+	{Title: "REPOS", Value: `{{ with .Repos }}{{ repolist . }}{{ end }}`},
+	{Title: "USERS", Value: `{{ with .Users }}{{ userlist . }}{{ end }}`},
+	{Title: "TEAMS", Value: `{{ with .Teams }}{{ teamlist . }}{{ end }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
+// tableOrgUserList defines the columns within org user listing.
+var tableOrgUserList = []outputColumn{
+	{Title: "ID", Value: `{{ .User.ID }}`},
+	{Title: "SLUG", Value: `{{ .User.Slug }}`},
+	{Title: "USERNAME", Value: `{{ .User.Username }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+}
+
+// tableOrgTeamList defines the columns within org team listing.
+var tableOrgTeamList = []outputColumn{
+	{Title: "ID", Value: `{{ .Team.ID }}`},
+	{Title: "SLUG", Value: `{{ .Team.Slug }}`},
+	{Title: "NAME", Value: `{{ .Team.Name }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+}
+
 // Org provides the sub-command for the org API.
 func Org() *cli.Command {
 	return &cli.Command{
@@ -56,23 +90,13 @@ func Org() *cli.Command {
 				Aliases:   []string{"ls"},
 				Usage:     "List all orgs",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplOrgList,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
+						Name:  "registry",
+						Value: "",
+						Usage: "Registry ID or slug to list orgs for",
 					},
-				},
+				}, listFlags(tmplOrgList)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, OrgList)
 				},
@@ -81,28 +105,13 @@ func Org() *cli.Command {
 				Name:      "show",
 				Usage:     "Display a org",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "id, i",
 						Value: "",
 						Usage: "Org ID or slug to show",
 					},
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplOrgShow,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				}, outputFlags(tmplOrgShow)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, OrgShow)
 				},
@@ -182,28 +191,13 @@ func Org() *cli.Command {
 						Aliases:   []string{"ls"},
 						Usage:     "List assigned users",
 						ArgsUsage: " ",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "id, i",
 								Value: "",
 								Usage: "Org ID or slug to list users",
 							},
-							&cli.StringFlag{
-								Name:  "format",
-								Value: tmplOrgUserList,
-								Usage: "Custom output format",
-							},
-							&cli.BoolFlag{
-								Name:  "json",
-								Value: false,
-								Usage: "Print in JSON format",
-							},
-							&cli.BoolFlag{
-								Name:  "xml",
-								Value: false,
-								Usage: "Print in XML format",
-							},
-						},
+						}, listFlags(tmplOrgUserList)...),
 						Action: func(c *cli.Context) error {
 							return Handle(c, OrgUserList)
 						},
@@ -290,28 +284,13 @@ func Org() *cli.Command {
 						Aliases:   []string{"ls"},
 						Usage:     "List assigned teams",
 						ArgsUsage: " ",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "id, i",
 								Value: "",
 								Usage: "Org ID or slug to list teams",
 							},
-							&cli.StringFlag{
-								Name:  "format",
-								Value: tmplOrgTeamList,
-								Usage: "Custom output format",
-							},
-							&cli.BoolFlag{
-								Name:  "json",
-								Value: false,
-								Usage: "Print in JSON format",
-							},
-							&cli.BoolFlag{
-								Name:  "xml",
-								Value: false,
-								Usage: "Print in XML format",
-							},
-						},
+						}, listFlags(tmplOrgTeamList)...),
 						Action: func(c *cli.Context) error {
 							return Handle(c, OrgTeamList)
 						},
@@ -401,60 +380,25 @@ func OrgList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
+	if val := c.String("registry"); val != "" {
+		id, err := ResolveID(client, "registry", val)
 
 		if err != nil {
 			return err
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
+		scoped := make([]*umschlag.Org, 0, len(records))
 
-		if err != nil {
-			return err
+		for _, record := range records {
+			if record.RegistryID == id {
+				scoped = append(scoped, record)
+			}
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
+		records = scoped
 	}
 
-	return nil
+	return RenderList(c, records, tableOrgList)
 }
 
 // OrgShow provides the sub-command to show org details.
@@ -467,47 +411,7 @@ func OrgShow(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableOrgShow)
 }
 
 // OrgDelete provides the sub-command to delete a org.
@@ -571,28 +475,14 @@ func OrgCreate(c *cli.Context, client umschlag.ClientAPI) error {
 		return fmt.Errorf("you must provide a registry id or slug")
 	}
 
-	if c.IsSet("registry") {
-		if match, _ := regexp.MatchString("^([0-9]+)$", c.String("registry")); match {
-			if val, err := strconv.ParseInt(c.String("registry"), 10, 64); err == nil && val != 0 {
-				record.RegistryID = val
-			}
-		} else {
-			if c.String("registry") != "" {
-				related, err := client.RegistryGet(
-					c.String("registry"),
-				)
-
-				if err != nil {
-					return err
-				}
-
-				if related.ID != record.RegistryID {
-					record.RegistryID = related.ID
-				}
-			}
-		}
+	id, err := ResolveID(client, "registry", c.String("registry"))
+
+	if err != nil {
+		return err
 	}
 
+	record.RegistryID = id
+
 	if val := c.String("slug"); c.IsSet("slug") && val != "" {
 		record.Slug = val
 	}
@@ -603,7 +493,7 @@ func OrgCreate(c *cli.Context, client umschlag.ClientAPI) error {
 		return fmt.Errorf("you must provide a name")
 	}
 
-	_, err := client.OrgPost(
+	_, err = client.OrgPost(
 		record,
 	)
 
@@ -627,60 +517,7 @@ func OrgUserList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableOrgUserList)
 }
 
 // OrgUserAppend provides the sub-command to append a user to the org.
@@ -748,60 +585,7 @@ func OrgTeamList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableOrgTeamList)
 }
 
 // OrgTeamAppend provides the sub-command to append a team to the org.
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// outputFormats defines the formats supported by the output flag.
+var outputFormats = []string{
+	"table",
+	"wide",
+	"json",
+	"jsonl",
+	"yaml",
+	"xml",
+	"csv",
+	"tsv",
+	"template",
+}
+
+// outputColumn represents a single column of the tabular output formats.
+type outputColumn struct {
+	Title string
+	Value string
+	Wide  bool
+}
+
+// outputFlags returns the flags to select the output format, the template is
+// used as default for the template output.
+func outputFlags(tmpl string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   "",
+			Usage:   "Output format, can be " + strings.Join(outputFormats, ", "),
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: tmpl,
+			Usage: "Custom output format, implies the template output",
+		},
+		&cli.StringFlag{
+			Name:  "query",
+			Value: "",
+			Usage: "Query expression applied to the records before rendering",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Value: false,
+			Usage: "Print in JSON format, deprecated in favor of --output json",
+		},
+		&cli.BoolFlag{
+			Name:  "xml",
+			Value: false,
+			Usage: "Print in XML format, deprecated in favor of --output xml",
+		},
+	}
+}
+
+// validOutput checks if the format is supported by the output flag.
+func validOutput(format string) bool {
+	for _, val := range outputFormats {
+		if val == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outputFormat resolves the requested output format, the deprecated flags
+// win over the output flag, a custom template implies the template output.
+func outputFormat(c *cli.Context) (string, error) {
+	if c.IsSet("json") && c.IsSet("xml") {
+		return "", fmt.Errorf("conflict, you can only use json or xml at once")
+	}
+
+	if c.Bool("json") {
+		fmt.Fprintf(os.Stderr, "warning: --json is deprecated, use --output json\n")
+		return "json", nil
+	}
+
+	if c.Bool("xml") {
+		fmt.Fprintf(os.Stderr, "warning: --xml is deprecated, use --output xml\n")
+		return "xml", nil
+	}
+
+	if val := c.String("output"); val != "" {
+		if !validOutput(val) {
+			return "", fmt.Errorf("invalid output, can be %s", strings.Join(outputFormats, ", "))
+		}
+
+		return val, nil
+	}
+
+	if c.IsSet("format") {
+		return "template", nil
+	}
+
+	return "table", nil
+}
+
+// RenderList renders a list of records with the requested output format.
+func RenderList(c *cli.Context, records interface{}, columns []outputColumn) error {
+	format, err := outputFormat(c)
+
+	if err != nil {
+		return err
+	}
+
+	records, err = applyListOptions(c, records)
+
+	if err != nil {
+		return err
+	}
+
+	if c.String("query") != "" {
+		return renderQuery(c, format, records)
+	}
+
+	items := listItems(records)
+
+	if items == nil {
+		items = []interface{}{}
+	}
+
+	switch format {
+	case "json":
+		return renderJSON(os.Stdout, items)
+	case "jsonl":
+		return renderJSONL(os.Stdout, items)
+	case "yaml":
+		return renderYAML(os.Stdout, items)
+	case "xml":
+		return renderXML(os.Stdout, records)
+	case "csv":
+		return renderCSV(os.Stdout, ',', items, columns)
+	case "tsv":
+		return renderCSV(os.Stdout, '\t', items, columns)
+	}
+
+	if len(items) == 0 {
+		fmt.Fprintf(os.Stderr, "Empty result\n")
+		return nil
+	}
+
+	switch format {
+	case "table":
+		return renderTable(os.Stdout, items, columns, false)
+	case "wide":
+		return renderTable(os.Stdout, items, columns, true)
+	}
+
+	return renderTemplate(os.Stdout, items, c.String("format"))
+}
+
+// RenderRecord renders a single record with the requested output format.
+func RenderRecord(c *cli.Context, record interface{}, columns []outputColumn) error {
+	format, err := outputFormat(c)
+
+	if err != nil {
+		return err
+	}
+
+	if c.String("query") != "" {
+		return renderQuery(c, format, record)
+	}
+
+	switch format {
+	case "json":
+		return renderJSON(os.Stdout, record)
+	case "jsonl":
+		return renderJSONL(os.Stdout, []interface{}{record})
+	case "yaml":
+		return renderYAML(os.Stdout, record)
+	case "xml":
+		return renderXML(os.Stdout, record)
+	case "csv":
+		return renderCSV(os.Stdout, ',', []interface{}{record}, columns)
+	case "tsv":
+		return renderCSV(os.Stdout, '\t', []interface{}{record}, columns)
+	case "table":
+		return renderDetails(os.Stdout, record, columns, false)
+	case "wide":
+		return renderDetails(os.Stdout, record, columns, true)
+	}
+
+	return renderTemplate(os.Stdout, []interface{}{record}, c.String("format"))
+}
+
+// renderQuery applies the query and renders the result, as the result can
+// have any shape the tabular formats print plain values one per line.
+func renderQuery(c *cli.Context, format string, val interface{}) error {
+	query, err := CompileQuery(c.String("query"))
+
+	if err != nil {
+		return err
+	}
+
+	result, err := query.Apply(val)
+
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return renderJSON(os.Stdout, result)
+	case "jsonl":
+		return renderJSONL(os.Stdout, listItems(result))
+	case "yaml":
+		return renderYAML(os.Stdout, result)
+	case "xml":
+		return fmt.Errorf("xml output is not supported for queries")
+	case "csv":
+		return renderGenericCSV(os.Stdout, ',', listItems(result))
+	case "tsv":
+		return renderGenericCSV(os.Stdout, '\t', listItems(result))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if _, ok := result.(map[string]interface{}); ok {
+		return renderJSON(os.Stdout, result)
+	}
+
+	for _, item := range listItems(result) {
+		fmt.Fprintf(os.Stdout, "%s\n", plainValue(item))
+	}
+
+	return nil
+}
+
+// listItems converts any slice into a list of generic items.
+func listItems(records interface{}) []interface{} {
+	if items, ok := records.([]interface{}); ok {
+		return items
+	}
+
+	val := reflect.ValueOf(records)
+
+	if val.Kind() != reflect.Slice {
+		return []interface{}{records}
+	}
+
+	items := make([]interface{}, val.Len())
+
+	for i := 0; i < val.Len(); i++ {
+		items[i] = val.Index(i).Interface()
+	}
+
+	return items
+}
+
+// renderJSON prints the value as indented JSON.
+func renderJSON(w io.Writer, val interface{}) error {
+	res, err := json.MarshalIndent(val, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s\n", res)
+	return nil
+}
+
+// renderJSONL prints every item as JSON on a single line.
+func renderJSONL(w io.Writer, items []interface{}) error {
+	enc := json.NewEncoder(w)
+
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderYAML prints the value as YAML, the keys are taken from the JSON
+// representation to stay consistent between both formats.
+func renderYAML(w io.Writer, val interface{}) error {
+	generic, err := toGeneric(val)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := yaml.Marshal(generic)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s", res)
+	return nil
+}
+
+// renderXML prints the value as indented XML.
+func renderXML(w io.Writer, val interface{}) error {
+	res, err := xml.MarshalIndent(val, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s\n", res)
+	return nil
+}
+
+// renderCSV prints all columns including a header with the given separator.
+func renderCSV(w io.Writer, comma rune, items []interface{}, columns []outputColumn) error {
+	tmpls, err := parseColumns(columns, true)
+
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	out.Comma = comma
+
+	header := make([]string, 0, len(tmpls))
+
+	for _, col := range columns {
+		header = append(header, col.Title)
+	}
+
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		row, err := executeColumns(tmpls, item)
+
+		if err != nil {
+			return err
+		}
+
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// renderGenericCSV prints generic values, objects are split into columns
+// based on the keys of the first object.
+func renderGenericCSV(w io.Writer, comma rune, items []interface{}) error {
+	out := csv.NewWriter(w)
+	out.Comma = comma
+
+	keys := []string{}
+
+	if len(items) > 0 {
+		if obj, ok := items[0].(map[string]interface{}); ok {
+			for key := range obj {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+		}
+	}
+
+	if len(keys) > 0 {
+		if err := out.Write(keys); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		row := []string{}
+
+		if obj, ok := item.(map[string]interface{}); ok && len(keys) > 0 {
+			for _, key := range keys {
+				row = append(row, plainValue(obj[key]))
+			}
+		} else {
+			row = append(row, plainValue(item))
+		}
+
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// plainValue prints strings without quotes and anything else as JSON.
+func plainValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	res, err := json.Marshal(val)
+
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+
+	return string(res)
+}
+
+// renderTable prints the items as aligned table, wide includes all columns.
+func renderTable(w io.Writer, items []interface{}, columns []outputColumn, wide bool) error {
+	tmpls, err := parseColumns(columns, wide)
+
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	header := []string{}
+
+	for _, col := range columns {
+		if col.Wide && !wide {
+			continue
+		}
+
+		header = append(header, col.Title)
+	}
+
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, item := range items {
+		row, err := executeColumns(tmpls, item)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// renderDetails prints a single item as aligned list of columns.
+func renderDetails(w io.Writer, item interface{}, columns []outputColumn, wide bool) error {
+	tmpls, err := parseColumns(columns, wide)
+
+	if err != nil {
+		return err
+	}
+
+	row, err := executeColumns(tmpls, item)
+
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	i := 0
+
+	for _, col := range columns {
+		if col.Wide && !wide {
+			continue
+		}
+
+		if row[i] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", detailsLabel(col.Title), row[i])
+		}
+
+		i++
+	}
+
+	return tw.Flush()
+}
+
+// detailsLabel converts a column title into a label for the details view,
+// short titles like ID are kept as they are.
+func detailsLabel(title string) string {
+	if len(title) <= 2 {
+		return title
+	}
+
+	return strings.Title(strings.ToLower(title))
+}
+
+// renderTemplate executes the custom template for every item.
+func renderTemplate(w io.Writer, items []interface{}, format string) error {
+	tmpl, err := template.New(
+		"_",
+	).Funcs(
+		globalFuncMap,
+	).Funcs(
+		sprigFuncMap,
+	).Parse(
+		fmt.Sprintf("%s\n", format),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := tmpl.Execute(w, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseColumns parses the templates of the columns, wide includes all columns.
+func parseColumns(columns []outputColumn, wide bool) ([]*template.Template, error) {
+	result := make([]*template.Template, 0, len(columns))
+
+	for _, col := range columns {
+		if col.Wide && !wide {
+			continue
+		}
+
+		tmpl, err := template.New(
+			col.Title,
+		).Funcs(
+			globalFuncMap,
+		).Funcs(
+			sprigFuncMap,
+		).Parse(
+			col.Value,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, tmpl)
+	}
+
+	return result, nil
+}
+
+// executeColumns executes the column templates for a single item.
+func executeColumns(tmpls []*template.Template, item interface{}) ([]string, error) {
+	result := make([]string, 0, len(tmpls))
+
+	for _, tmpl := range tmpls {
+		buf := bytes.NewBufferString("")
+
+		if err := tmpl.Execute(buf, item); err != nil {
+			return nil, err
+		}
+
+		result = append(result, strings.Replace(buf.String(), "\n", " ", -1))
+	}
+
+	return result, nil
+}
+
+// toGeneric converts a value into its generic JSON representation.
+func toGeneric(val interface{}) (interface{}, error) {
+	res, err := json.Marshal(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+
+	if err := json.Unmarshal(res, &generic); err != nil {
+		return nil, err
+	}
+
+	return generic, nil
+}
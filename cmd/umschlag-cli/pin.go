@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/umschlag/umschlag-cli/pkg/distribution"
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplPin represents a row within the pin report.
+var tmplPin = `Image: {{ highlight .Image }}
+File: {{ .File }}:{{ .Line }}
+Status: {{ .Status }}{{ with .Digest }}
+Digest: {{ . }}{{ end }}{{ with .Error }}
+Error: {{ danger . }}{{ end }}
+`
+
+// tablePin defines the columns within the pin report.
+var tablePin = []outputColumn{
+	{Title: "FILE", Value: `{{ .File }}:{{ .Line }}`},
+	{Title: "IMAGE", Value: `{{ .Image }}`},
+	{Title: "STATUS", Value: `{{ .Status }}`},
+	{Title: "DIGEST", Value: `{{ .Digest }}`, Wide: true},
+	{Title: "ERROR", Value: `{{ .Error }}`, Wide: true},
+}
+
+// pinPatterns match image references within Dockerfiles, docker-compose files
+// and Kubernetes manifests, the reference is always the last group.
+var pinPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^(\s*FROM\s+(?:--\S+\s+)*)([^\s#]+)`),
+	regexp.MustCompile(`^(\s*(?:-\s+)?image:\s*["']?)([^\s"'#]+)`),
+}
+
+// pinRecord represents a single image reference within the pin report.
+type pinRecord struct {
+	XMLName xml.Name `json:"-" xml:"reference"`
+	File    string   `json:"file" xml:"file"`
+	Line    int      `json:"line" xml:"line"`
+	Image   string   `json:"image" xml:"image"`
+	Digest  string   `json:"digest,omitempty" xml:"digest,omitempty"`
+	Status  string   `json:"status" xml:"status"`
+	Error   string   `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// pinResolver resolves tags to digests for all registries managed by
+// Umschlag, clients and digests are cached across files.
+type pinResolver struct {
+	c          *cli.Context
+	client     umschlag.ClientAPI
+	registries []*umschlag.Registry
+	clients    map[string]*distribution.Client
+	digests    map[string]string
+}
+
+// registry returns the client for the host if it is managed by Umschlag.
+func (r *pinResolver) registry(host string) (*distribution.Client, error) {
+	host = normalizeHost(host)
+
+	if registry, ok := r.clients[host]; ok {
+		return registry, nil
+	}
+
+	for _, record := range r.registries {
+		if normalizeHost(record.Host) != host {
+			continue
+		}
+
+		registry, err := newRegistryClient(r.c, r.client, record)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.clients[host] = registry
+		return registry, nil
+	}
+
+	return nil, nil
+}
+
+// digest resolves the tag of the reference to the current digest.
+func (r *pinResolver) digest(registry *distribution.Client, ref *imageReference) (string, error) {
+	tag := ref.Tag
+
+	if tag == "" {
+		tag = "latest"
+	}
+
+	key := normalizeHost(ref.Host) + "/" + ref.Path + ":" + tag
+
+	if val, ok := r.digests[key]; ok {
+		return val, nil
+	}
+
+	val, err := registry.Digest(ref.Path, tag)
+
+	if err != nil {
+		return "", err
+	}
+
+	r.digests[key] = val
+	return val, nil
+}
+
+// Pin provides the sub-command to pin image references to digests.
+func Pin() *cli.Command {
+	return &cli.Command{
+		Name:      "pin",
+		Usage:     "Pin image references within files to digests",
+		ArgsUsage: "<file>...",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "check",
+				Value: false,
+				Usage: "Only check the references and fail if they are unpinned or drifted",
+			},
+		}, outputFlags(tmplPin)...),
+		Action: func(c *cli.Context) error {
+			return Handle(c, PinAction)
+		},
+	}
+}
+
+// PinAction scans the files for image references hosted on registries
+// managed by Umschlag and pins them to the current digest.
+func PinAction(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("you must provide at least one file")
+	}
+
+	registries, err := client.RegistryList()
+
+	if err != nil {
+		return err
+	}
+
+	resolver := &pinResolver{
+		c:          c,
+		client:     client,
+		registries: registries,
+		clients:    map[string]*distribution.Client{},
+		digests:    map[string]string{},
+	}
+
+	records := []*pinRecord{}
+
+	for _, file := range c.Args().Slice() {
+		result, err := pinFile(resolver, file, c.Bool("check"))
+
+		if err != nil {
+			return err
+		}
+
+		records = append(records, result...)
+	}
+
+	if err := RenderList(c, records, tablePin); err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, record := range records {
+		switch record.Status {
+		case "error":
+			failed++
+		case "unpinned", "drifted":
+			if c.Bool("check") {
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		if c.Bool("check") {
+			return fmt.Errorf("found %d unpinned, drifted or unresolvable references", failed)
+		}
+
+		return fmt.Errorf("failed to resolve %d references", failed)
+	}
+
+	return nil
+}
+
+// pinFile processes the references of a single file, the file only gets
+// written if references have been updated.
+func pinFile(resolver *pinResolver, file string, check bool) ([]*pinRecord, error) {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*pinRecord{}
+	lines := []string{}
+	changed := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for num := 1; scanner.Scan(); num++ {
+		line := scanner.Text()
+
+		for _, pattern := range pinPatterns {
+			match := pattern.FindStringSubmatchIndex(line)
+
+			if match == nil {
+				continue
+			}
+
+			raw := line[match[4]:match[5]]
+			ref, err := parseImageReference(raw)
+
+			if err != nil || ref.Host == "" || (ref.Tag == "" && ref.Digest != "") {
+				break
+			}
+
+			registry, err := resolver.registry(ref.Host)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if registry == nil {
+				break
+			}
+
+			record := &pinRecord{
+				File:  file,
+				Line:  num,
+				Image: raw,
+			}
+
+			records = append(records, record)
+			digest, err := resolver.digest(registry, ref)
+
+			if err != nil {
+				record.Status = "error"
+				record.Error = err.Error()
+
+				break
+			}
+
+			record.Digest = digest
+
+			switch {
+			case ref.Digest == digest:
+				record.Status = "pinned"
+			case check && ref.Digest == "":
+				record.Status = "unpinned"
+			case check:
+				record.Status = "drifted"
+			default:
+				record.Status = "updated"
+				ref.Digest = digest
+
+				// Keep the implicit tag, otherwise the digest-only reference
+				// would be skipped by later checks.
+				if ref.Tag == "" {
+					ref.Tag = "latest"
+				}
+
+				line = line[:match[4]] + ref.String() + line[match[5]:]
+				changed = true
+			}
+
+			break
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !changed {
+		return records, nil
+	}
+
+	output := strings.Join(lines, "\n")
+
+	if bytes.HasSuffix(content, []byte("\n")) {
+		output = output + "\n"
+	}
+
+	return records, writeFileAtomic(file, []byte(output), 0644)
+}
+
+// writeFileAtomic replaces the file through a temporary file within the same
+// directory and keeps the permissions of the original file, perm is only used
+// for new files.
+func writeFileAtomic(file string, content []byte, perm os.FileMode) error {
+	mode := perm
+
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode()
+	}
+
+	return replaceFile(file, content, mode)
+}
+
+// writeSecretFile replaces the file like writeFileAtomic, but the file is
+// always only readable by the owner as it contains secrets.
+func writeSecretFile(file string, content []byte) error {
+	return replaceFile(file, content, 0600)
+}
+
+// replaceFile writes the content to a temporary file with the given mode and
+// renames it to the file afterwards.
+func replaceFile(file string, content []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// TagResolve provides the sub-command to resolve a tag to its digest.
+func TagResolve(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("you must provide a reference like repo:tag")
+	}
+
+	ref, err := parseImageReference(c.Args().First())
+
+	if err != nil {
+		return err
+	}
+
+	if ref.Tag == "" {
+		ref.Tag = "latest"
+	}
+
+	record, path, err := resolveRepoImage(client, ref)
+
+	if err != nil {
+		return err
+	}
+
+	registry, err := newRegistryClient(c, client, record)
+
+	if err != nil {
+		return err
+	}
+
+	if val := c.String("platform"); val != "" {
+		selector, err := distribution.ParsePlatform(val)
+
+		if err != nil {
+			return err
+		}
+
+		manifest, err := registry.Manifest(path, ref.Tag)
+
+		if err != nil {
+			return err
+		}
+
+		selected, _, err := selectPlatform(registry, path, manifest, selector)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stdout, selected.Digest)
+		return nil
+	}
+
+	digest, err := registry.Digest(path, ref.Tag)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, digest)
+	return nil
+}
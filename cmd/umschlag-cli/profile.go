@@ -1,18 +1,15 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"text/template"
 
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplProfileShow represents a profile within details view.
-var tmplProfileShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplProfileShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Username: {{ .Username }}
 Email: {{ .Email }}
@@ -22,6 +19,18 @@ Created: {{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
+// tableProfileShow defines the columns within profile details view.
+var tableProfileShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "USERNAME", Value: `{{ .Username }}`},
+	{Title: "EMAIL", Value: `{{ .Email }}`},
+	{Title: "ACTIVE", Value: `{{ .Active }}`},
+	{Title: "ADMIN", Value: `{{ .Admin }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
 // Profile provides the sub-command for the profile API.
 func Profile() *cli.Command {
 	return &cli.Command{
@@ -31,23 +40,7 @@ func Profile() *cli.Command {
 			{
 				Name:  "show",
 				Usage: "Show profile details",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplProfileShow,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				Flags: outputFlags(tmplProfileShow),
 				Action: func(c *cli.Context) error {
 					return Handle(c, ProfileShow)
 				},
@@ -112,47 +105,7 @@ func ProfileShow(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableProfileShow)
 }
 
 // ProfileToken provides the sub-command to show your token.
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// tmplTagPrune represents a row within the prune report.
+var tmplTagPrune = `Tag: {{ highlight .Tag.FullName }}
+Action: {{ .Action }}
+Reason: {{ .Reason }}{{ with .Error }}
+Error: {{ danger . }}{{ end }}
+`
+
+// tableTagPrune defines the columns within the prune report.
+var tableTagPrune = []outputColumn{
+	{Title: "ACTION", Value: `{{ .Action }}`},
+	{Title: "TAG", Value: `{{ .Tag.FullName }}`},
+	{Title: "CREATED", Value: `{{ .Tag.CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "REASON", Value: `{{ .Reason }}`},
+	{Title: "ERROR", Value: `{{ .Error }}`, Wide: true},
+}
+
+// agePattern matches the day and week units not supported by time.
+var agePattern = regexp.MustCompile("^([0-9]+)(d|w)$")
+
+// PruneOptions defines the retention rules to prune tags.
+type PruneOptions struct {
+	Repo             string
+	Org              string
+	KeepLast         int
+	OlderThan        time.Duration
+	Match            *regexp.Regexp
+	Exclude          *regexp.Regexp
+	KeepSemverLatest bool
+	Concurrency      int
+	Delete           bool
+}
+
+// PruneDecision represents the decision about a single tag.
+type PruneDecision struct {
+	XMLName xml.Name      `json:"-" xml:"decision"`
+	Tag     *umschlag.Tag `json:"tag" xml:"tag"`
+	Action  string        `json:"action" xml:"action"`
+	Reason  string        `json:"reason" xml:"reason"`
+	Error   string        `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// PruneResult represents the outcome of a prune run.
+type PruneResult struct {
+	Decisions []*PruneDecision
+	Total     int
+	Kept      int
+	Deleted   int
+	Failed    int
+}
+
+// ParseAge parses a duration, it supports days and weeks in addition to the
+// units of time.ParseDuration.
+func ParseAge(val string) (time.Duration, error) {
+	if match := agePattern.FindStringSubmatch(val); match != nil {
+		num, err := strconv.Atoi(match[1])
+
+		if err != nil {
+			return 0, err
+		}
+
+		if match[2] == "w" {
+			return time.Duration(num) * 7 * 24 * time.Hour, nil
+		}
+
+		return time.Duration(num) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(val)
+}
+
+// formatAge prints a duration in days if possible.
+func formatAge(val time.Duration) string {
+	if val%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", val/(24*time.Hour))
+	}
+
+	return val.String()
+}
+
+// Validate checks if the options define at least one rule to delete tags.
+func (o *PruneOptions) Validate() error {
+	if o.Repo != "" && o.Org != "" {
+		return fmt.Errorf("conflict, you can only use repo or org at once")
+	}
+
+	if o.KeepLast < 0 {
+		return fmt.Errorf("keep-last must not be negative")
+	}
+
+	if o.KeepLast == 0 && o.OlderThan == 0 && o.Match == nil {
+		return fmt.Errorf("you must provide keep-last, older-than or match")
+	}
+
+	return nil
+}
+
+// Prune fetches the tags within the scope, decides which tags to delete and
+// deletes them if the options allow it. No further deletions are started
+// once the context is done, running deletions are finished.
+func Prune(ctx context.Context, client umschlag.ClientAPI, opts *PruneOptions) (*PruneResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	tags, err := pruneScope(client, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PruneResult{
+		Decisions: PlanPrune(tags, opts, time.Now()),
+		Total:     len(tags),
+	}
+
+	deletions := []*PruneDecision{}
+
+	for _, decision := range result.Decisions {
+		if decision.Action == "delete" {
+			deletions = append(deletions, decision)
+		} else {
+			result.Kept++
+		}
+	}
+
+	if !opts.Delete {
+		result.Deleted = len(deletions)
+		return result, nil
+	}
+
+	concurrency := opts.Concurrency
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+
+	slots := make(chan struct{}, concurrency)
+
+	for _, decision := range deletions {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+
+		go func(decision *PruneDecision) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := client.TagDelete(
+				strconv.FormatInt(decision.Tag.ID, 10),
+			)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				decision.Action = "failed"
+				decision.Error = err.Error()
+				result.Failed++
+
+				return
+			}
+
+			decision.Action = "deleted"
+			result.Deleted++
+		}(decision)
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("stopped after deleting %d of %d tags: %s", result.Deleted, len(deletions), err)
+	}
+
+	return result, nil
+}
+
+// pruneScope fetches all tags limited to the repo or org of the options.
+func pruneScope(client umschlag.ClientAPI, opts *PruneOptions) ([]*umschlag.Tag, error) {
+	repos := map[int64]bool{}
+
+	switch {
+	case opts.Repo != "":
+		id, err := ResolveID(client, "repo", opts.Repo)
+
+		if err != nil {
+			return nil, err
+		}
+
+		repos[id] = true
+	case opts.Org != "":
+		id, err := ResolveID(client, "org", opts.Org)
+
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := client.RepoList()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			if record.OrgID == id {
+				repos[record.ID] = true
+			}
+		}
+	}
+
+	records, err := client.TagList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Repo == "" && opts.Org == "" {
+		return records, nil
+	}
+
+	tags := make([]*umschlag.Tag, 0, len(records))
+
+	for _, record := range records {
+		if repos[record.RepoID] {
+			tags = append(tags, record)
+		}
+	}
+
+	return tags, nil
+}
+
+// PlanPrune decides for every tag if it gets kept or deleted, the keep rules
+// are evaluated per repo and always win over the delete rules.
+func PlanPrune(tags []*umschlag.Tag, opts *PruneOptions, now time.Time) []*PruneDecision {
+	grouped := map[int64][]*umschlag.Tag{}
+	order := []int64{}
+
+	for _, tag := range tags {
+		if _, ok := grouped[tag.RepoID]; !ok {
+			order = append(order, tag.RepoID)
+		}
+
+		grouped[tag.RepoID] = append(grouped[tag.RepoID], tag)
+	}
+
+	decisions := make([]*PruneDecision, 0, len(tags))
+
+	for _, repo := range order {
+		group := grouped[repo]
+
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].CreatedAt.After(group[j].CreatedAt)
+		})
+
+		latest := semverLatest(group)
+		position := 0
+
+		for _, tag := range group {
+			decision := &PruneDecision{
+				Tag:    tag,
+				Action: "keep",
+			}
+
+			decisions = append(decisions, decision)
+
+			if opts.Match != nil && !opts.Match.MatchString(tag.Name) {
+				decision.Reason = "does not match " + opts.Match.String()
+				continue
+			}
+
+			if opts.Exclude != nil && opts.Exclude.MatchString(tag.Name) {
+				decision.Reason = "excluded by " + opts.Exclude.String()
+				continue
+			}
+
+			if opts.KeepSemverLatest && tag == latest {
+				decision.Reason = "latest semver release"
+				continue
+			}
+
+			position++
+
+			if position <= opts.KeepLast {
+				decision.Reason = fmt.Sprintf("within last %d", opts.KeepLast)
+				continue
+			}
+
+			if opts.OlderThan > 0 && now.Sub(tag.CreatedAt) < opts.OlderThan {
+				decision.Reason = "newer than " + formatAge(opts.OlderThan)
+				continue
+			}
+
+			reasons := []string{}
+
+			if opts.KeepLast > 0 {
+				reasons = append(reasons, fmt.Sprintf("beyond last %d", opts.KeepLast))
+			}
+
+			if opts.OlderThan > 0 {
+				reasons = append(reasons, "older than "+formatAge(opts.OlderThan))
+			}
+
+			if opts.Match != nil {
+				reasons = append(reasons, "matches "+opts.Match.String())
+			}
+
+			decision.Action = "delete"
+			decision.Reason = strings.Join(reasons, ", ")
+		}
+	}
+
+	return decisions
+}
+
+// semverLatest returns the highest release of the tags, prereleases are only
+// considered if there is no release at all.
+func semverLatest(tags []*umschlag.Tag) *umschlag.Tag {
+	var (
+		latest  *umschlag.Tag
+		version *semver.Version
+		stable  bool
+	)
+
+	for _, tag := range tags {
+		current, err := semver.NewVersion(tag.Name)
+
+		if err != nil {
+			continue
+		}
+
+		release := current.Prerelease() == ""
+
+		if version == nil || (release && !stable) || (release == stable && current.GreaterThan(version)) {
+			latest = tag
+			version = current
+			stable = release
+		}
+	}
+
+	return latest
+}
+
+// TagPrune provides the sub-command to prune tags by retention rules.
+func TagPrune(c *cli.Context, client umschlag.ClientAPI) error {
+	opts := &PruneOptions{
+		Repo:             c.String("repo"),
+		Org:              c.String("org"),
+		KeepLast:         c.Int("keep-last"),
+		KeepSemverLatest: c.Bool("keep-semver-latest"),
+		Concurrency:      c.Int("concurrency"),
+		Delete:           c.Bool("yes") && !(c.IsSet("dry-run") && c.Bool("dry-run")),
+	}
+
+	if val := c.String("older-than"); val != "" {
+		age, err := ParseAge(val)
+
+		if err != nil {
+			return fmt.Errorf("invalid older-than %s: %s", val, err)
+		}
+
+		opts.OlderThan = age
+	}
+
+	if val := c.String("match"); val != "" {
+		regex, err := regexp.Compile(val)
+
+		if err != nil {
+			return fmt.Errorf("invalid match %s: %s", val, err)
+		}
+
+		opts.Match = regex
+	}
+
+	if val := c.String("exclude"); val != "" {
+		regex, err := regexp.Compile(val)
+
+		if err != nil {
+			return fmt.Errorf("invalid exclude %s: %s", val, err)
+		}
+
+		opts.Exclude = regex
+	}
+
+	result, err := Prune(context.Background(), client, opts)
+
+	if err != nil {
+		return err
+	}
+
+	records := []*PruneDecision{}
+
+	for _, decision := range result.Decisions {
+		if decision.Action != "keep" || c.Bool("show-kept") {
+			records = append(records, decision)
+		}
+	}
+
+	if err := RenderList(c, records, tableTagPrune); err != nil {
+		return err
+	}
+
+	if !opts.Delete {
+		fmt.Fprintf(os.Stderr, "Dry run, would delete %d of %d tags and keep %d, use --yes to delete\n", result.Deleted, result.Total, result.Kept)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Deleted %d of %d tags, kept %d, failed %d\n", result.Deleted, result.Total, result.Kept, result.Failed)
+
+	if result.Failed > 0 {
+		return fmt.Errorf("failed to delete %d tags", result.Failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// pullSecretType defines the type of Kubernetes image pull secrets.
+const pullSecretType = "kubernetes.io/dockerconfigjson"
+
+// pullSecret represents a Kubernetes image pull secret.
+type pullSecret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   pullSecretMeta    `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string]string `json:"data"`
+}
+
+// pullSecretMeta represents the metadata of the image pull secret.
+type pullSecretMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// dockerAuth represents a single entry within the auths of a docker config.
+type dockerAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+// newDockerAuth encodes the credentials the way docker expects them.
+func newDockerAuth(username, password string) *dockerAuth {
+	return &dockerAuth{
+		Username: username,
+		Password: password,
+		Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+	}
+}
+
+// dockerConfigPath returns the docker config file, it honors DOCKER_CONFIG.
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return filepath.Join(".docker", "config.json")
+	}
+
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// mergeDockerConfig adds the auth for the host to the docker config, all
+// other settings, auths and fields of the host entry are kept as they are.
+func mergeDockerConfig(path, host string, auth *dockerAuth) error {
+	config := map[string]interface{}{}
+	content, err := ioutil.ReadFile(path)
+
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case len(content) > 0:
+		if err := json.Unmarshal(content, &config); err != nil {
+			return fmt.Errorf("failed to parse %s: %s", path, err)
+		}
+	}
+
+	auths, ok := config["auths"].(map[string]interface{})
+
+	if !ok {
+		auths = map[string]interface{}{}
+	}
+
+	entry, ok := auths[host].(map[string]interface{})
+
+	if !ok {
+		entry = map[string]interface{}{}
+	}
+
+	entry["auth"] = auth.Auth
+	auths[host] = entry
+
+	config["auths"] = auths
+
+	if helpers, ok := config["credHelpers"].(map[string]interface{}); ok {
+		if helper, ok := helpers[host]; ok {
+			fmt.Fprintf(os.Stderr, "warning: docker uses the credential helper %v for %s instead\n", helper, host)
+		}
+	}
+
+	result, err := json.MarshalIndent(config, "", "\t")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, append(result, '\n'), 0600)
+}
+
+// RegistryPullSecret provides the sub-command to generate pull secrets.
+func RegistryPullSecret(c *cli.Context, client umschlag.ClientAPI) error {
+	registry, err := client.RegistryGet(
+		GetIdentifierParam(c),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	username, secret, err := registryCredentials(c, client)
+
+	if err != nil {
+		return err
+	}
+
+	host := normalizeHost(registry.Host)
+	auth := newDockerAuth(username, secret)
+
+	if c.Bool("docker-config") {
+		path := c.String("docker-config-path")
+
+		if path == "" {
+			path = dockerConfigPath()
+		}
+
+		if err := mergeDockerConfig(path, host, auth); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Successfully added %s to %s\n", host, path)
+		return nil
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]*dockerAuth{
+			host: auth,
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+
+	if name == "" {
+		name = registry.Slug + "-pull-secret"
+	}
+
+	record := &pullSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: pullSecretMeta{
+			Name:      name,
+			Namespace: c.String("namespace"),
+		},
+		Type: pullSecretType,
+		Data: map[string]string{
+			".dockerconfigjson": base64.StdEncoding.EncodeToString(config),
+		},
+	}
+
+	switch c.String("secret-format") {
+	case "json":
+		return renderJSON(os.Stdout, record)
+	case "yaml":
+		return renderYAML(os.Stdout, record)
+	}
+
+	return fmt.Errorf("invalid secret format %s, can be yaml or json", c.String("secret-format"))
+}
@@ -0,0 +1,1083 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The query language is a small subset of JMESPath which also accepts the
+// most common jq notations. It operates on the JSON representation of the
+// records, field names match both the JSON keys and the Go field names, so
+// FullName and full_name select the same value.
+//
+//   FullName, .full_name    select a field
+//   [], .[]                 flatten a list and project the rest of the path
+//   [0], [-1]               select a single element
+//   [1:3], [:5], [::2]      slice a list and project the rest of the path
+//   [?Public == `true`]     filter a list and project the rest of the path
+//   {name: FullName, id: ID} build a new object from the current value
+//   map(expr)               apply the expression to every element of a list
+//   expr | expr             stop a projection and continue on its result
+//   .[] | .Name             like jq, a path with a leading dot continues on
+//                           every element of a projection instead
+//
+// Filters support ==, !=, <, <=, >, >=, =~ for regular expressions as well as
+// &&, || and ! for combining conditions. Literals can be quoted strings,
+// numbers, true, false and null. Other functions are not supported.
+
+// queryStep represents a single step within a query path.
+type queryStep interface{}
+
+// queryField selects a field of an object.
+type queryField struct {
+	name string
+}
+
+// queryIndex selects a single element of a list.
+type queryIndex struct {
+	index int
+}
+
+// querySlice selects a range of a list and starts a projection.
+type querySlice struct {
+	start, stop, step *int
+}
+
+// queryFlatten flattens a list and starts a projection.
+type queryFlatten struct{}
+
+// queryFilter filters a list and starts a projection.
+type queryFilter struct {
+	cond queryExpr
+}
+
+// queryMap applies an expression to every element of a list.
+type queryMap struct {
+	expr queryExpr
+}
+
+// queryHash builds a new object from the current value.
+type queryHash struct {
+	keys  []string
+	exprs []queryExpr
+}
+
+// queryExpr represents any evaluable expression.
+type queryExpr interface {
+	eval(val interface{}) (interface{}, error)
+}
+
+// queryPipe evaluates expressions one after another.
+type queryPipe struct {
+	exprs []queryExpr
+}
+
+// queryPath evaluates a chain of steps, dot marks paths with a leading dot.
+type queryPath struct {
+	steps []queryStep
+	dot   bool
+}
+
+// queryLiteral represents a constant value.
+type queryLiteral struct {
+	val interface{}
+}
+
+// queryCompare compares two expressions.
+type queryCompare struct {
+	op          string
+	left, right queryExpr
+}
+
+// queryLogic combines two conditions.
+type queryLogic struct {
+	op          string
+	left, right queryExpr
+}
+
+// queryNot negates a condition.
+type queryNot struct {
+	expr queryExpr
+}
+
+// Query represents a compiled query expression.
+type Query struct {
+	raw  string
+	expr queryExpr
+}
+
+// CompileQuery parses the query expression.
+func CompileQuery(raw string) (*Query, error) {
+	tokens, err := lexQuery(raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %s", err)
+	}
+
+	p := &queryParser{
+		tokens: tokens,
+	}
+
+	expr, err := p.parsePipe()
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %s", err)
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("invalid query: unexpected %q", p.peek().val)
+	}
+
+	return &Query{
+		raw:  raw,
+		expr: expr,
+	}, nil
+}
+
+// Apply evaluates the query against the JSON representation of the value.
+func (q *Query) Apply(val interface{}) (interface{}, error) {
+	generic, err := toGeneric(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return q.expr.eval(generic)
+}
+
+func (e *queryPipe) eval(val interface{}) (interface{}, error) {
+	var (
+		err       error
+		projected bool
+	)
+
+	for _, expr := range e.exprs {
+		path, ok := expr.(*queryPath)
+		list, isList := val.([]interface{})
+
+		if !projected || !ok || !path.dot || !isList {
+			if val, err = expr.eval(val); err != nil {
+				return nil, err
+			}
+
+			projected = ok && path.projects()
+			continue
+		}
+
+		result := []interface{}{}
+
+		for _, item := range list {
+			res, err := expr.eval(item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if res != nil {
+				result = append(result, res)
+			}
+		}
+
+		val = result
+	}
+
+	return val, nil
+}
+
+// projects checks if the path contains a step starting a projection.
+func (e *queryPath) projects() bool {
+	for _, step := range e.steps {
+		switch step.(type) {
+		case *queryFlatten, *querySlice, *queryFilter:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *queryPath) eval(val interface{}) (interface{}, error) {
+	return evalSteps(e.steps, val)
+}
+
+func (e *queryLiteral) eval(val interface{}) (interface{}, error) {
+	return e.val, nil
+}
+
+func (e *queryCompare) eval(val interface{}) (interface{}, error) {
+	left, err := e.left.eval(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.right.eval(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	case "=~":
+		str, ok := left.(string)
+
+		if !ok {
+			return false, nil
+		}
+
+		pattern, ok := right.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("regular expression must be a string")
+		}
+
+		re, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return re.MatchString(str), nil
+	}
+
+	cmp, ok := compareValues(left, right)
+
+	if !ok {
+		return false, nil
+	}
+
+	switch e.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %s", e.op)
+}
+
+func (e *queryLogic) eval(val interface{}) (interface{}, error) {
+	left, err := e.left.eval(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if e.op == "&&" && !truthy(left) {
+		return left, nil
+	}
+
+	if e.op == "||" && truthy(left) {
+		return left, nil
+	}
+
+	return e.right.eval(val)
+}
+
+func (e *queryNot) eval(val interface{}) (interface{}, error) {
+	res, err := e.expr.eval(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return !truthy(res), nil
+}
+
+// evalSteps applies the steps to the value, projections apply the remaining
+// steps to every element and drop empty results.
+func evalSteps(steps []queryStep, val interface{}) (interface{}, error) {
+	for i, step := range steps {
+		switch s := step.(type) {
+		case *queryField:
+			obj, ok := val.(map[string]interface{})
+
+			if !ok {
+				return nil, nil
+			}
+
+			val = lookupField(obj, s.name)
+		case *queryIndex:
+			list, ok := val.([]interface{})
+
+			if !ok {
+				return nil, nil
+			}
+
+			idx := s.index
+
+			if idx < 0 {
+				idx += len(list)
+			}
+
+			if idx < 0 || idx >= len(list) {
+				return nil, nil
+			}
+
+			val = list[idx]
+		case *queryHash:
+			if val == nil {
+				return nil, nil
+			}
+
+			obj := make(map[string]interface{}, len(s.keys))
+
+			for j, key := range s.keys {
+				res, err := s.exprs[j].eval(val)
+
+				if err != nil {
+					return nil, err
+				}
+
+				obj[key] = res
+			}
+
+			val = obj
+		case *queryMap:
+			list, ok := val.([]interface{})
+
+			if !ok {
+				return nil, nil
+			}
+
+			result := make([]interface{}, 0, len(list))
+
+			for _, item := range list {
+				res, err := s.expr.eval(item)
+
+				if err != nil {
+					return nil, err
+				}
+
+				result = append(result, res)
+			}
+
+			val = result
+		default:
+			list, ok := val.([]interface{})
+
+			if !ok {
+				return nil, nil
+			}
+
+			items, err := projectItems(step, list)
+
+			if err != nil {
+				return nil, err
+			}
+
+			result := []interface{}{}
+
+			for _, item := range items {
+				res, err := evalSteps(steps[i+1:], item)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if res != nil {
+					result = append(result, res)
+				}
+			}
+
+			return result, nil
+		}
+	}
+
+	return val, nil
+}
+
+// projectItems returns the elements a projection step applies to.
+func projectItems(step queryStep, list []interface{}) ([]interface{}, error) {
+	switch s := step.(type) {
+	case *queryFlatten:
+		result := []interface{}{}
+
+		for _, item := range list {
+			if nested, ok := item.([]interface{}); ok {
+				result = append(result, nested...)
+			} else {
+				result = append(result, item)
+			}
+		}
+
+		return result, nil
+	case *querySlice:
+		return sliceItems(list, s), nil
+	case *queryFilter:
+		result := []interface{}{}
+
+		for _, item := range list {
+			res, err := s.cond.eval(item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if truthy(res) {
+				result = append(result, item)
+			}
+		}
+
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("unknown query step")
+}
+
+// sliceItems implements python like slicing including negative values.
+func sliceItems(list []interface{}, s *querySlice) []interface{} {
+	length := len(list)
+	step := 1
+
+	if s.step != nil {
+		step = *s.step
+	}
+
+	if step == 0 {
+		return []interface{}{}
+	}
+
+	bound := func(val *int, def int) int {
+		if val == nil {
+			return def
+		}
+
+		res := *val
+
+		if res < 0 {
+			res += length
+		}
+
+		if step > 0 {
+			return clamp(res, 0, length)
+		}
+
+		return clamp(res, -1, length-1)
+	}
+
+	result := []interface{}{}
+
+	if step > 0 {
+		for i := bound(s.start, 0); i < bound(s.stop, length); i += step {
+			result = append(result, list[i])
+		}
+	} else {
+		for i := bound(s.start, length-1); i > bound(s.stop, -1); i += step {
+			result = append(result, list[i])
+		}
+	}
+
+	return result
+}
+
+// clamp limits the value to the given range.
+func clamp(val, min, max int) int {
+	if val < min {
+		return min
+	}
+
+	if val > max {
+		return max
+	}
+
+	return val
+}
+
+// lookupField matches the name against the keys of the object, first exact
+// and afterwards ignoring casing and underscores.
+func lookupField(obj map[string]interface{}, name string) interface{} {
+	if val, ok := obj[name]; ok {
+		return val
+	}
+
+	norm := normalizeField(name)
+
+	for key, val := range obj {
+		if normalizeField(key) == norm {
+			return val
+		}
+	}
+
+	return nil
+}
+
+// normalizeField drops casing and underscores from a field name.
+func normalizeField(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+// truthy follows the JMESPath definition of true values.
+func truthy(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+
+	return true
+}
+
+// compareValues compares numbers or strings, other types are not ordered.
+func compareValues(left, right interface{}) (int, bool) {
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case l < r:
+			return -1, true
+		case l > r:
+			return 1, true
+		}
+
+		return 0, true
+	case string:
+		r, ok := right.(string)
+
+		if !ok {
+			return 0, false
+		}
+
+		return strings.Compare(l, r), true
+	}
+
+	return 0, false
+}
+
+// queryToken represents a single lexed token.
+type queryToken struct {
+	kind string
+	val  string
+}
+
+// lexQuery splits the query into tokens.
+func lexQuery(raw string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	runes := []rune(raw)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			j := i + 1
+
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' {
+					j++
+				}
+
+				j++
+			}
+
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+
+			val := strings.Replace(string(runes[i+1:j]), "\\"+string(r), string(r), -1)
+
+			if r == '`' {
+				tokens = append(tokens, queryToken{"literal", val})
+			} else {
+				tokens = append(tokens, queryToken{"string", val})
+			}
+
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+
+			tokens = append(tokens, queryToken{"number", string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '-') {
+				j++
+			}
+
+			tokens = append(tokens, queryToken{"ident", string(runes[i:j])})
+			i = j
+		default:
+			if i+1 < len(runes) {
+				switch op := string(runes[i : i+2]); op {
+				case "==", "!=", "<=", ">=", "=~", "&&", "||":
+					tokens = append(tokens, queryToken{"op", op})
+					i += 2
+					continue
+				}
+			}
+
+			switch r {
+			case '<', '>':
+				tokens = append(tokens, queryToken{"op", string(r)})
+			case '.', '[', ']', '{', '}', '(', ')', ':', ',', '|', '?', '@', '!':
+				tokens = append(tokens, queryToken{string(r), string(r)})
+			default:
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+// queryParser implements a recursive descent parser for queries.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.done() {
+		return queryToken{"eof", "end of query"}
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) peekAt(offset int) queryToken {
+	if p.pos+offset >= len(p.tokens) {
+		return queryToken{"eof", "end of query"}
+	}
+
+	return p.tokens[p.pos+offset]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.peek()
+	p.pos++
+
+	return tok
+}
+
+func (p *queryParser) expect(kind string) (queryToken, error) {
+	tok := p.next()
+
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %q, got %q", kind, tok.val)
+	}
+
+	return tok, nil
+}
+
+// parsePipe parses expressions separated by pipes.
+func (p *queryParser) parsePipe() (queryExpr, error) {
+	expr, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := &queryPipe{
+		exprs: []queryExpr{expr},
+	}
+
+	for p.peek().kind == "|" {
+		p.next()
+
+		expr, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		pipe.exprs = append(pipe.exprs, expr)
+	}
+
+	if len(pipe.exprs) == 1 {
+		return pipe.exprs[0], nil
+	}
+
+	return pipe, nil
+}
+
+// parseOr parses conditions combined by ||.
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == "op" && p.peek().val == "||" {
+		p.next()
+
+		right, err := p.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = &queryLogic{"||", left, right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses conditions combined by &&.
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseNot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == "op" && p.peek().val == "&&" {
+		p.next()
+
+		right, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = &queryLogic{"&&", left, right}
+	}
+
+	return left, nil
+}
+
+// parseNot parses negations and grouped conditions.
+func (p *queryParser) parseNot() (queryExpr, error) {
+	switch p.peek().kind {
+	case "!":
+		p.next()
+
+		expr, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &queryNot{expr}, nil
+	case "(":
+		p.next()
+
+		expr, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return expr, nil
+	}
+
+	return p.parseCompare()
+}
+
+// parseCompare parses an operand optionally compared to another one.
+func (p *queryParser) parseCompare() (queryExpr, error) {
+	left, err := p.parseOperand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind == "op" && tok.val != "&&" && tok.val != "||" {
+		p.next()
+
+		right, err := p.parseOperand()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &queryCompare{tok.val, left, right}, nil
+	}
+
+	return left, nil
+}
+
+// parseOperand parses a literal or a path.
+func (p *queryParser) parseOperand() (queryExpr, error) {
+	tok := p.peek()
+
+	switch tok.kind {
+	case "string":
+		p.next()
+		return &queryLiteral{tok.val}, nil
+	case "number":
+		p.next()
+
+		val, err := strconv.ParseFloat(tok.val, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &queryLiteral{val}, nil
+	case "literal":
+		p.next()
+
+		val, err := parseLiteral(tok.val)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &queryLiteral{val}, nil
+	case "ident":
+		switch tok.val {
+		case "true":
+			p.next()
+			return &queryLiteral{true}, nil
+		case "false":
+			p.next()
+			return &queryLiteral{false}, nil
+		case "null":
+			p.next()
+			return &queryLiteral{nil}, nil
+		}
+	}
+
+	return p.parsePath()
+}
+
+// parsePath parses a chain of steps, a leading dot or @ refers to the
+// current value.
+func (p *queryParser) parsePath() (queryExpr, error) {
+	path := &queryPath{}
+
+	switch p.peek().kind {
+	case "@":
+		p.next()
+	case ".":
+		p.next()
+		path.dot = true
+
+		if p.peek().kind == "ident" {
+			path.steps = append(path.steps, &queryField{p.next().val})
+		}
+	case "ident":
+		if p.peekAt(1).kind == "(" {
+			step, err := p.parseFunction()
+
+			if err != nil {
+				return nil, err
+			}
+
+			path.steps = append(path.steps, step)
+			break
+		}
+
+		path.steps = append(path.steps, &queryField{p.next().val})
+	case "[", "{":
+	default:
+		return nil, fmt.Errorf("unexpected %q", p.peek().val)
+	}
+
+	for {
+		switch p.peek().kind {
+		case ".":
+			p.next()
+
+			switch p.peek().kind {
+			case "ident":
+				path.steps = append(path.steps, &queryField{p.next().val})
+			case "{", "[":
+				continue
+			default:
+				return nil, fmt.Errorf("unexpected %q after dot", p.peek().val)
+			}
+		case "[":
+			step, err := p.parseBracket()
+
+			if err != nil {
+				return nil, err
+			}
+
+			path.steps = append(path.steps, step)
+		case "{":
+			step, err := p.parseHash()
+
+			if err != nil {
+				return nil, err
+			}
+
+			path.steps = append(path.steps, step)
+		default:
+			return path, nil
+		}
+	}
+}
+
+// parseBracket parses flatten, index, slice and filter steps.
+func (p *queryParser) parseBracket() (queryStep, error) {
+	p.next()
+
+	switch p.peek().kind {
+	case "]":
+		p.next()
+		return &queryFlatten{}, nil
+	case "?":
+		p.next()
+
+		cond, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect("]"); err != nil {
+			return nil, err
+		}
+
+		return &queryFilter{cond}, nil
+	}
+
+	parts := []*int{nil}
+
+	for {
+		tok := p.next()
+
+		switch tok.kind {
+		case "number":
+			val, err := strconv.Atoi(tok.val)
+
+			if err != nil {
+				return nil, err
+			}
+
+			parts[len(parts)-1] = &val
+		case ":":
+			if len(parts) == 3 {
+				return nil, fmt.Errorf("too many colons in slice")
+			}
+
+			parts = append(parts, nil)
+		case "]":
+			if len(parts) == 1 {
+				if parts[0] == nil {
+					return nil, fmt.Errorf("missing index")
+				}
+
+				return &queryIndex{*parts[0]}, nil
+			}
+
+			for len(parts) < 3 {
+				parts = append(parts, nil)
+			}
+
+			return &querySlice{parts[0], parts[1], parts[2]}, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q within brackets", tok.val)
+		}
+	}
+}
+
+// parseFunction parses a function call, only map is supported.
+func (p *queryParser) parseFunction() (queryStep, error) {
+	name := p.next().val
+	p.next()
+
+	if name != "map" {
+		return nil, fmt.Errorf("unsupported function %s()", name)
+	}
+
+	expr, err := p.parsePipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return &queryMap{expr}, nil
+}
+
+// parseHash parses an object built from key and expression pairs.
+func (p *queryParser) parseHash() (queryStep, error) {
+	p.next()
+
+	hash := &queryHash{}
+
+	for {
+		key := p.next()
+
+		if key.kind != "ident" && key.kind != "string" {
+			return nil, fmt.Errorf("expected key, got %q", key.val)
+		}
+
+		if _, err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		expr, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		hash.keys = append(hash.keys, key.val)
+		hash.exprs = append(hash.exprs, expr)
+
+		switch tok := p.next(); tok.kind {
+		case ",":
+			continue
+		case "}":
+			return hash, nil
+		default:
+			return nil, fmt.Errorf("expected \",\" or \"}\", got %q", tok.val)
+		}
+	}
+}
+
+// parseLiteral parses a backtick literal, bare words are treated as strings.
+func parseLiteral(raw string) (interface{}, error) {
+	switch raw = strings.TrimSpace(raw); raw {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	if val, err := strconv.ParseFloat(raw, 64); err == nil {
+		return val, nil
+	}
+
+	return strings.Trim(raw, "\""), nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// queryRepo represents a record like the ones returned by the API.
+type queryRepo struct {
+	ID       int64    `json:"id"`
+	Slug     string   `json:"slug"`
+	Name     string   `json:"name"`
+	Public   bool     `json:"public"`
+	Size     int64    `json:"size"`
+	Tags     []string `json:"tags"`
+	Registry struct {
+		Slug string `json:"slug"`
+	} `json:"registry"`
+}
+
+// queryRepos returns the records used by all query tests.
+func queryRepos() []*queryRepo {
+	repos := []*queryRepo{
+		{ID: 1, Slug: "alpine", Name: "Alpine", Public: true, Size: 5, Tags: []string{"3.9", "latest"}},
+		{ID: 2, Slug: "debian", Name: "Debian", Public: false, Size: 50, Tags: []string{"stretch"}},
+		{ID: 3, Slug: "golang", Name: "Golang", Public: true, Size: 300, Tags: []string{}},
+		{ID: 4, Slug: "nginx", Name: "Nginx", Public: false, Size: 20, Tags: []string{"1.15", "1.16"}},
+	}
+
+	repos[0].Registry.Slug = "hub"
+	repos[1].Registry.Slug = "hub"
+	repos[2].Registry.Slug = "quay"
+	repos[3].Registry.Slug = "quay"
+
+	return repos
+}
+
+// runQuery compiles and applies the query, the result gets encoded as JSON.
+func runQuery(t *testing.T, raw string, val interface{}) string {
+	t.Helper()
+
+	query, err := CompileQuery(raw)
+
+	if err != nil {
+		t.Fatalf("failed to compile %q: %s", raw, err)
+	}
+
+	res, err := query.Apply(val)
+
+	if err != nil {
+		t.Fatalf("failed to apply %q: %s", raw, err)
+	}
+
+	out, err := json.Marshal(res)
+
+	if err != nil {
+		t.Fatalf("failed to encode result of %q: %s", raw, err)
+	}
+
+	return string(out)
+}
+
+func TestQueryProjection(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{`[].Slug`, `["alpine","debian","golang","nginx"]`},
+		{`.[].slug`, `["alpine","debian","golang","nginx"]`},
+		{`[].registry.slug`, `["hub","hub","quay","quay"]`},
+		{`[].Tags[0]`, `["3.9","stretch","1.15"]`},
+		{`[].Missing`, `[]`},
+		{`@ | [1].full_name`, `null`},
+		{`@ | [1].Name`, `"Debian"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := runQuery(t, tt.query, queryRepos()); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{`[?Public].Slug`, `["alpine","golang"]`},
+		{`[?!Public].Slug`, `["debian","nginx"]`},
+		{`[?Slug == 'nginx'].ID`, `[4]`},
+		{`[?Slug != "nginx"].ID`, `[1,2,3]`},
+		{`[?Size > 20].Slug`, `["debian","golang"]`},
+		{`[?Size <= 20].Slug`, `["alpine","nginx"]`},
+		{`[?Slug =~ '^(al|ng)'].Slug`, `["alpine","nginx"]`},
+		{`[?Public && Size > 10].Slug`, `["golang"]`},
+		{`[?Size < 10 || Size > 100].Slug`, `["alpine","golang"]`},
+		{`[?!(Public || Size > 30)].Slug`, `["nginx"]`},
+		{`[?Tags].Slug`, `["alpine","debian","nginx"]`},
+		{`[?registry.slug == 'quay'].Slug`, `["golang","nginx"]`},
+		{`[?Slug == 'unknown'].Slug`, `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := runQuery(t, tt.query, queryRepos()); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestQuerySlice(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{`[0].Slug`, `"alpine"`},
+		{`[-1].Slug`, `"nginx"`},
+		{`[10]`, `null`},
+		{`[1:3].Slug`, `["debian","golang"]`},
+		{`[:2].Slug`, `["alpine","debian"]`},
+		{`[2:].Slug`, `["golang","nginx"]`},
+		{`[-2:].Slug`, `["golang","nginx"]`},
+		{`[:-3].Slug`, `["alpine"]`},
+		{`[::2].Slug`, `["alpine","golang"]`},
+		{`[::-1].Slug`, `["nginx","golang","debian","alpine"]`},
+		{`[5:10].Slug`, `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := runQuery(t, tt.query, queryRepos()); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestQueryHash(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{`[0].{name: Name, id: ID}`, `{"id":1,"name":"Alpine"}`},
+		{`[?Public].{slug: Slug, registry: registry.slug}`, `[{"registry":"hub","slug":"alpine"},{"registry":"quay","slug":"golang"}]`},
+		{`[-1].{tags: Tags[-1]}`, `{"tags":"1.16"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := runQuery(t, tt.query, queryRepos()); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestQueryPipe(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{`[?Public] | [0].Slug`, `"alpine"`},
+		{`[].Slug | [-1]`, `"nginx"`},
+		{`[?Public].Slug | [1]`, `"golang"`},
+		{`.[] | .Slug`, `["alpine","debian","golang","nginx"]`},
+		{`.[] | .registry | .slug`, `["hub","hub","quay","quay"]`},
+		{`.[1:3] | .Name`, `["Debian","Golang"]`},
+		{`.[] | .Missing`, `[]`},
+		{`map(.Slug)`, `["alpine","debian","golang","nginx"]`},
+		{`map(Tags[0])`, `["3.9","stretch",null,"1.15"]`},
+		{`map({slug: Slug}) | [0]`, `{"slug":"alpine"}`},
+		{`[?Public] | map(.ID)`, `[1,3]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := runQuery(t, tt.query, queryRepos()); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestQueryRecord(t *testing.T) {
+	repo := queryRepos()[0]
+
+	if got := runQuery(t, `.Slug`, repo); got != `"alpine"` {
+		t.Errorf("expected \"alpine\", got %s", got)
+	}
+
+	if got := runQuery(t, `Tags | [0]`, repo); got != `"3.9"` {
+		t.Errorf("expected \"3.9\", got %s", got)
+	}
+
+	if got := runQuery(t, `map(.Slug)`, repo); got != `null` {
+		t.Errorf("expected null, got %s", got)
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`[?Slug == 'alpine].Slug`, "unterminated string"},
+		{`[0`, "within brackets"},
+		{`[1:2:3:4]`, ""},
+		{`[?Slug =~ '(']`, ""},
+		{`length(@)`, "unsupported function length()"},
+		{`sort_by(Slug)`, "unsupported function sort_by()"},
+		{`map(.Slug`, `expected ")"`},
+		{`[].Slug ]`, "unexpected"},
+		{`{name Name}`, ""},
+		{`$`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := CompileQuery(tt.query)
+
+			if err == nil {
+				_, err = query.Apply(queryRepos())
+			}
+
+			if err == nil {
+				t.Fatalf("expected an error for %q", tt.query)
+			}
+
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected %q within %q", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+)
+
+// imageReference represents a parsed docker image reference.
+type imageReference struct {
+	Host   string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// parseImageReference splits a reference like host/path:tag@digest, the first
+// path component is only treated as host if it looks like one.
+func parseImageReference(raw string) (*imageReference, error) {
+	if raw == "" || strings.ContainsAny(raw, " \t$") {
+		return nil, fmt.Errorf("invalid image reference %s", raw)
+	}
+
+	result := &imageReference{}
+	rest := raw
+
+	if i := strings.Index(rest, "@"); i >= 0 {
+		result.Digest = rest[i+1:]
+		rest = rest[:i]
+
+		if !strings.HasPrefix(result.Digest, "sha256:") {
+			return nil, fmt.Errorf("invalid digest within image reference %s", raw)
+		}
+	}
+
+	if i := strings.LastIndex(rest, ":"); i >= 0 && !strings.Contains(rest[i+1:], "/") {
+		result.Tag = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		first := rest[:i]
+
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			result.Host = first
+			rest = rest[i+1:]
+		}
+	}
+
+	if rest == "" {
+		return nil, fmt.Errorf("invalid image reference %s", raw)
+	}
+
+	result.Path = rest
+	return result, nil
+}
+
+// String formats the reference again.
+func (r *imageReference) String() string {
+	val := r.Path
+
+	if r.Host != "" {
+		val = r.Host + "/" + val
+	}
+
+	if r.Tag != "" {
+		val = val + ":" + r.Tag
+	}
+
+	if r.Digest != "" {
+		val = val + "@" + r.Digest
+	}
+
+	return val
+}
+
+// resolveRepoImage finds the registry for a reference, the repo is matched by
+// full name or slug if the reference doesn't contain a registry host.
+func resolveRepoImage(client umschlag.ClientAPI, ref *imageReference) (*umschlag.Registry, string, error) {
+	if ref.Host != "" {
+		registry, err := findRegistry(client, ref.Host)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if registry == nil {
+			return nil, "", fmt.Errorf("registry %s is not managed by umschlag", ref.Host)
+		}
+
+		return registry, ref.Path, nil
+	}
+
+	records, err := client.RepoList()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	var repo *umschlag.Repo
+
+	for _, record := range records {
+		if record.FullName == ref.Path {
+			repo = record
+			break
+		}
+
+		if record.Slug == ref.Path {
+			if repo != nil {
+				return nil, "", fmt.Errorf("repo %s is ambiguous, use the full name", ref.Path)
+			}
+
+			repo = record
+		}
+	}
+
+	if repo == nil {
+		return nil, "", fmt.Errorf("repo %s not found", ref.Path)
+	}
+
+	org, err := client.OrgGet(
+		strconv.FormatInt(repo.OrgID, 10),
+	)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	registry, err := client.RegistryGet(
+		strconv.FormatInt(org.RegistryID, 10),
+	)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return registry, repo.FullName, nil
+}
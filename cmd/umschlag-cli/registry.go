@@ -1,24 +1,22 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"text/template"
+	"time"
 
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplRegistryList represents a row within registry listing.
-var tmplRegistryList = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplRegistryList = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .Name }}
 `
 
 // tmplRegistryShow represents a registry within details view.
-var tmplRegistryShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplRegistryShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .Name }}
 Host: {{ .Host }}{{with .Orgs}}
@@ -27,6 +25,27 @@ Created: {{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
+// tableRegistryList defines the columns within registry listing.
+var tableRegistryList = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "HOST", Value: `{{ .Host }}`, Wide: true},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+}
+
+// tableRegistryShow defines the columns within registry details view.
+var tableRegistryShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "HOST", Value: `{{ .Host }}`},
+	{Title: "ORGS", Value: `{{ with .Orgs }}{{ orglist . }}{{ end }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
 // Registry provides the sub-command for the registry API.
 func Registry() *cli.Command {
 	return &cli.Command{
@@ -38,23 +57,7 @@ func Registry() *cli.Command {
 				Aliases:   []string{"ls"},
 				Usage:     "List all registries",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplRegistryList,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				Flags:     listFlags(tmplRegistryList),
 				Action: func(c *cli.Context) error {
 					return Handle(c, RegistryList)
 				},
@@ -63,28 +66,13 @@ func Registry() *cli.Command {
 				Name:      "show",
 				Usage:     "Display a registry",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "id, i",
 						Value: "",
 						Usage: "Registry ID or slug to show",
 					},
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplRegistryShow,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				}, outputFlags(tmplRegistryShow)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, RegistryShow)
 				},
@@ -115,11 +103,121 @@ func Registry() *cli.Command {
 						Value: "",
 						Usage: "Registry ID or slug to sync",
 					},
+					&cli.BoolFlag{
+						Name:  "wait",
+						Value: false,
+						Usage: "Wait for the sync to finish, exits with 3 on timeout and 4 if the sync can't be triggered",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Value: 10 * time.Minute,
+						Usage: "Maximum time to wait for the sync, at least 5 intervals",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 2 * time.Second,
+						Usage: "Interval to poll the sync status",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					return Handle(c, RegistrySync)
 				},
 			},
+			{
+				Name:      "diff",
+				Usage:     "Compare the registry catalog with Umschlag",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "Registry ID or slug to compare",
+					},
+					&cli.BoolFlag{
+						Name:  "fix",
+						Value: false,
+						Usage: "Sync missing entries and delete extra or stale records",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Value: false,
+						Usage: "Delete extra or stale records without confirmation",
+					},
+				}, outputFlags(tmplRegistryDiff)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, RegistryDiff)
+				},
+			},
+			{
+				Name:      "pull-secret",
+				Usage:     "Generate an image pull secret for a registry",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "Registry ID or slug to generate the secret for",
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Value: "",
+						Usage: "Name of the secret, defaults to <slug>-pull-secret",
+					},
+					&cli.StringFlag{
+						Name:  "namespace",
+						Value: "",
+						Usage: "Namespace of the secret",
+					},
+					&cli.StringFlag{
+						Name:  "secret-format",
+						Value: "yaml",
+						Usage: "Format of the generated secret, yaml or json",
+					},
+					&cli.BoolFlag{
+						Name:  "docker-config",
+						Value: false,
+						Usage: "Merge the credentials into the docker config instead",
+					},
+					&cli.StringFlag{
+						Name:  "docker-config-path",
+						Value: "",
+						Usage: "Path to the docker config, defaults to ~/.docker/config.json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return Handle(c, RegistryPullSecret)
+				},
+			},
+			{
+				Name:      "check",
+				Usage:     "Check the connectivity of registries",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "Registry ID or slug to check",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Value: false,
+						Usage: "Check all registries",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Value: 10 * time.Second,
+						Usage: "Timeout for contacting a registry",
+					},
+					&cli.StringFlag{
+						Name:  "expiry-warning",
+						Value: "14d",
+						Usage: "Warn if a certificate expires within this age, like 14d or 2w",
+					},
+				}, outputFlags(tmplRegistryCheck)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, RegistryCheck)
+				},
+			},
 			{
 				Name:      "update",
 				Usage:     "Update a registry",
@@ -145,6 +243,11 @@ func Registry() *cli.Command {
 						Value: "",
 						Usage: "Provide an host",
 					},
+					&cli.BoolFlag{
+						Name:  "skip-check",
+						Value: false,
+						Usage: "Skip the connectivity check of the host",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					return Handle(c, RegistryUpdate)
@@ -170,6 +273,11 @@ func Registry() *cli.Command {
 						Value: "",
 						Usage: "Provide an host",
 					},
+					&cli.BoolFlag{
+						Name:  "skip-check",
+						Value: false,
+						Usage: "Skip the connectivity check of the host",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					return Handle(c, RegistryCreate)
@@ -187,60 +295,7 @@ func RegistryList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableRegistryList)
 }
 
 // RegistryShow provides the sub-command to show registry details.
@@ -253,47 +308,7 @@ func RegistryShow(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableRegistryShow)
 }
 
 // RegistryDelete provides the sub-command to delete a registry.
@@ -310,20 +325,6 @@ func RegistryDelete(c *cli.Context, client umschlag.ClientAPI) error {
 	return nil
 }
 
-// RegistrySync provides the sub-command to sync a registry.
-func RegistrySync(c *cli.Context, client umschlag.ClientAPI) error {
-	err := client.RegistrySync(
-		GetIdentifierParam(c),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	fmt.Fprintf(os.Stderr, "Successfully synced\n")
-	return nil
-}
-
 // RegistryUpdate provides the sub-command to update a registry.
 func RegistryUpdate(c *cli.Context, client umschlag.ClientAPI) error {
 	record, err := client.RegistryGet(
@@ -335,6 +336,7 @@ func RegistryUpdate(c *cli.Context, client umschlag.ClientAPI) error {
 	}
 
 	changed := false
+	hostChanged := false
 
 	if val := c.String("slug"); c.IsSet("slug") && val != record.Slug {
 		record.Slug = val
@@ -349,6 +351,13 @@ func RegistryUpdate(c *cli.Context, client umschlag.ClientAPI) error {
 	if val := c.String("host"); c.IsSet("host") && val != record.Host {
 		record.Host = val
 		changed = true
+		hostChanged = true
+	}
+
+	if hostChanged {
+		if err := validateRegistryHost(c, record); err != nil {
+			return err
+		}
 	}
 
 	if changed {
@@ -388,6 +397,10 @@ func RegistryCreate(c *cli.Context, client umschlag.ClientAPI) error {
 		return fmt.Errorf("you must provide an host")
 	}
 
+	if err := validateRegistryHost(c, record); err != nil {
+		return err
+	}
+
 	_, err := client.RegistryPost(
 		record,
 	)
@@ -1,24 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"text/template"
 
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplRepoList represents a row within repo listing.
-var tmplRepoList = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplRepoList = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .FullName }}
 `
 
 // tmplRepoShow represents a repo within details view.
-var tmplRepoShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplRepoShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .FullName }}{{with .Tags}}
 Tags: {{ taglist . }}{{end}}
@@ -26,6 +23,27 @@ Created: {{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
+// tableRepoList defines the columns within repo listing.
+var tableRepoList = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .FullName }}`},
+	{Title: "PUBLIC", Value: `{{ .Public }}`, Wide: true},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+}
+
+// tableRepoShow defines the columns within repo details view.
+var tableRepoShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .FullName }}`},
+	{Title: "PUBLIC", Value: `{{ .Public }}`},
+	{Title: "TAGS", Value: `{{ with .Tags }}{{ taglist . }}{{ end }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
 // Repo provides the sub-command for the repo API.
 func Repo() *cli.Command {
 	return &cli.Command{
@@ -37,23 +55,13 @@ func Repo() *cli.Command {
 				Aliases:   []string{"ls"},
 				Usage:     "List all repos",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplRepoList,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
+						Name:  "org",
+						Value: "",
+						Usage: "Org ID or slug to list repos for",
 					},
-				},
+				}, listFlags(tmplRepoList)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, RepoList)
 				},
@@ -62,28 +70,13 @@ func Repo() *cli.Command {
 				Name:      "show",
 				Usage:     "Display a repo",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "id, i",
 						Value: "",
 						Usage: "Repo ID or slug to show",
 					},
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplRepoShow,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				}, outputFlags(tmplRepoShow)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, RepoShow)
 				},
@@ -116,60 +109,25 @@ func RepoList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
+	if val := c.String("org"); val != "" {
+		id, err := ResolveID(client, "org", val)
 
 		if err != nil {
 			return err
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
+		scoped := make([]*umschlag.Repo, 0, len(records))
 
-		if err != nil {
-			return err
+		for _, record := range records {
+			if record.OrgID == id {
+				scoped = append(scoped, record)
+			}
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
+		records = scoped
 	}
 
-	return nil
+	return RenderList(c, records, tableRepoList)
 }
 
 // RepoShow provides the sub-command to show repo details.
@@ -182,47 +140,7 @@ func RepoShow(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableRepoShow)
 }
 
 // RepoDelete provides the sub-command to delete a repo.
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// syncTimeoutCode defines the exit code if a sync doesn't finish in time.
+const syncTimeoutCode = 3
+
+// syncFailedCode defines the exit code if a triggered sync fails.
+const syncFailedCode = 4
+
+// syncMinPolls defines how often the status gets polled at least, that way
+// the server got some time to start the sync.
+const syncMinPolls = 5
+
+// syncStablePolls defines how often the counts have to match to treat a
+// sync as finished.
+const syncStablePolls = 2
+
+// syncMaxFailures defines how often polling the status may fail in a row
+// before waiting for the sync gets aborted.
+const syncMaxFailures = 3
+
+// errSyncTimeout gets returned if the sync doesn't finish in time.
+var errSyncTimeout = errors.New("timeout waiting for the sync to finish")
+
+// syncError wraps the error of triggering a sync to distinguish it from a
+// failed lookup of the registry or a failed poll of the status.
+type syncError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *syncError) Error() string {
+	return e.err.Error()
+}
+
+// SyncStatus represents the state of a registry during a sync.
+type SyncStatus struct {
+	Registry *umschlag.Registry
+	Orgs     int
+	Repos    int
+	Tags     int
+	Elapsed  time.Duration
+	Finished bool
+}
+
+// SyncOptions defines how to trigger and wait for a registry sync.
+type SyncOptions struct {
+	Wait     bool
+	Timeout  time.Duration
+	Interval time.Duration
+	Progress func(*SyncStatus)
+}
+
+// Validate checks the options, the timeout has to allow the minimum number
+// of polls as the sync can't finish before.
+func (o *SyncOptions) Validate() error {
+	if o.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	if o.Wait && o.Timeout > 0 && o.Timeout < syncMinPolls*o.Interval {
+		return fmt.Errorf("timeout must be at least %s, the status gets polled %d times every %s", syncMinPolls*o.Interval, syncMinPolls, o.Interval)
+	}
+
+	return nil
+}
+
+// SyncRegistry triggers the sync of a registry, if requested it waits until
+// the discovered records don't change anymore. The counts are not compared
+// before a minimum number of polls as the sync runs asynchronously. Failed
+// polls are retried, waiting stops once the context is done.
+func SyncRegistry(ctx context.Context, client umschlag.ClientAPI, id string, opts *SyncOptions) (*SyncStatus, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	before, err := client.RegistryGet(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.RegistrySync(id); err != nil {
+		return nil, &syncError{err}
+	}
+
+	if !opts.Wait {
+		return &SyncStatus{Registry: before}, nil
+	}
+
+	var (
+		start    = time.Now()
+		deadline = start.Add(opts.Timeout)
+		previous = &SyncStatus{Registry: before}
+		polls    = 0
+		stable   = 0
+		failures = 0
+	)
+
+	for {
+		status, err := syncStatus(client, id)
+
+		if err != nil {
+			if failures++; failures >= syncMaxFailures {
+				return nil, fmt.Errorf("failed to poll the sync status: %s", err)
+			}
+		} else {
+			failures = 0
+			polls++
+
+			status.Elapsed = time.Since(start)
+
+			if polls > 1 && previous.Orgs == status.Orgs && previous.Repos == status.Repos && previous.Tags == status.Tags {
+				stable++
+			} else {
+				stable = 0
+			}
+
+			status.Finished = polls >= syncMinPolls && stable >= syncStablePolls
+
+			if opts.Progress != nil {
+				opts.Progress(status)
+			}
+
+			if status.Finished {
+				return status, nil
+			}
+
+			previous = status
+		}
+
+		if opts.Timeout > 0 && time.Now().Add(opts.Interval).After(deadline) {
+			return previous, errSyncTimeout
+		}
+
+		select {
+		case <-time.After(opts.Interval):
+		case <-ctx.Done():
+			return previous, fmt.Errorf("stopped waiting for the sync: %s", ctx.Err())
+		}
+	}
+}
+
+// syncStatus counts the orgs, repos and tags discovered for the registry.
+func syncStatus(client umschlag.ClientAPI, id string) (*SyncStatus, error) {
+	registry, err := client.RegistryGet(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SyncStatus{
+		Registry: registry,
+	}
+
+	orgs, err := client.OrgList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	orgIDs := map[int64]bool{}
+
+	for _, org := range orgs {
+		if org.RegistryID == registry.ID {
+			orgIDs[org.ID] = true
+		}
+	}
+
+	repos, err := client.RepoList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	repoIDs := map[int64]bool{}
+
+	for _, repo := range repos {
+		if orgIDs[repo.OrgID] {
+			repoIDs[repo.ID] = true
+		}
+	}
+
+	tags, err := client.TagList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		if repoIDs[tag.RepoID] {
+			status.Tags++
+		}
+	}
+
+	status.Orgs = len(orgIDs)
+	status.Repos = len(repoIDs)
+
+	return status, nil
+}
+
+// syncProgress prints the progress as a single updating line on a terminal
+// and as plain log lines on changes otherwise.
+func syncProgress() func(*SyncStatus) {
+	tty := terminal.IsTerminal(int(os.Stderr.Fd()))
+	last := ""
+
+	return func(status *SyncStatus) {
+		line := fmt.Sprintf(
+			"%d orgs, %d repos, %d tags",
+			status.Orgs,
+			status.Repos,
+			status.Tags,
+		)
+
+		if tty {
+			fmt.Fprintf(os.Stderr, "\r\x1b[KSyncing %s: %s (%s)", status.Registry.Name, line, status.Elapsed.Round(time.Second))
+
+			if status.Finished {
+				fmt.Fprintf(os.Stderr, "\n")
+			}
+
+			return
+		}
+
+		if line != last {
+			fmt.Fprintf(os.Stderr, "%s sync of %s in progress, %s\n", time.Now().Format(time.RFC3339), status.Registry.Name, line)
+			last = line
+		}
+	}
+}
+
+// RegistrySync provides the sub-command to sync a registry.
+func RegistrySync(c *cli.Context, client umschlag.ClientAPI) error {
+	opts := &SyncOptions{
+		Wait:     c.Bool("wait"),
+		Timeout:  c.Duration("timeout"),
+		Interval: c.Duration("interval"),
+	}
+
+	if opts.Wait {
+		opts.Progress = syncProgress()
+	}
+
+	status, err := SyncRegistry(
+		context.Background(),
+		client,
+		GetIdentifierParam(c),
+		opts,
+	)
+
+	if err == errSyncTimeout {
+		if terminal.IsTerminal(int(os.Stderr.Fd())) {
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+
+		return &exitError{
+			code: syncTimeoutCode,
+			err:  fmt.Errorf("sync of %s did not finish within %s", status.Registry.Name, opts.Timeout),
+		}
+	}
+
+	if e, ok := err.(*syncError); ok {
+		return &exitError{
+			code: syncFailedCode,
+			err:  fmt.Errorf("sync of %s failed: %s", GetIdentifierParam(c), e.err),
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !opts.Wait {
+		fmt.Fprintf(os.Stderr, "Successfully triggered sync\n")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Successfully synced %s, %d orgs, %d repos, %d tags\n", status.Registry.Name, status.Orgs, status.Repos, status.Tags)
+	return nil
+}
@@ -1,30 +1,52 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"text/template"
 
+	"github.com/Masterminds/semver"
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplTagList represents a row within tag listing.
-var tmplTagList = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplTagList = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .FullName }}
 `
 
 // tmplTagShow represents a tag within details view.
-var tmplTagShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplTagShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .FullName }}
 Created: {{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
+// tmplTagLatest represents the tag name printed by the latest command, it
+// doesn't use the output flags to stay usable within scripts.
+var tmplTagLatest = `{{ .Name }}`
+
+// tableTagList defines the columns within tag listing.
+var tableTagList = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .FullName }}`},
+	{Title: "PUBLIC", Value: `{{ .Public }}`, Wide: true},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+}
+
+// tableTagShow defines the columns within tag details view.
+var tableTagShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .FullName }}`},
+	{Title: "PUBLIC", Value: `{{ .Public }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
 // Tag provides the sub-command for the tag API.
 func Tag() *cli.Command {
 	return &cli.Command{
@@ -36,55 +58,185 @@ func Tag() *cli.Command {
 				Aliases:   []string{"ls"},
 				Usage:     "List all tags",
 				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "repo",
+						Value: "",
+						Usage: "Repo ID or slug to list tags for",
+					},
+				}, listFlags(tmplTagList)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, TagList)
+				},
+			},
+			{
+				Name:      "show",
+				Usage:     "Display a tag",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "Tag ID or slug to show",
+					},
+				}, outputFlags(tmplTagShow)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, TagShow)
+				},
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Display the manifest of a tag",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "Tag ID or slug to inspect",
+					},
+					&cli.StringFlag{
+						Name:  "platform",
+						Value: "",
+						Usage: "Inspect the manifest of a platform, like linux/arm64",
+					},
+				}, outputFlags(tmplTagInspect)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, TagInspect)
+				},
+			},
+			{
+				Name:      "platforms",
+				Usage:     "List the platforms of a tag",
+				ArgsUsage: " ",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "Tag ID or slug to list platforms for",
+					},
+					&cli.StringFlag{
+						Name:  "platform",
+						Value: "",
+						Usage: "Only show the manifest of a platform, like linux/arm64",
+					},
+				}, listFlags(tmplTagPlatforms)...),
+				Action: func(c *cli.Context) error {
+					return Handle(c, TagPlatforms)
+				},
+			},
+			{
+				Name:      "resolve",
+				Usage:     "Resolve a tag to its digest",
+				ArgsUsage: "<repo:tag>",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplTagList,
-						Usage: "Custom output format",
+						Name:  "platform",
+						Value: "",
+						Usage: "Resolve the digest of a platform, like linux/arm64",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return Handle(c, TagResolve)
+				},
+			},
+			{
+				Name:      "latest",
+				Usage:     "Display the newest semver tag of a repo",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "repo",
+						Value: "",
+						Usage: "Repo ID or slug to search the tag in",
+					},
+					&cli.StringFlag{
+						Name:  "constraint",
+						Value: "",
+						Usage: "Semver constraint the tag must match, like ~1.4",
 					},
 					&cli.BoolFlag{
-						Name:  "json",
+						Name:  "prerelease",
 						Value: false,
-						Usage: "Print in JSON format",
+						Usage: "Include prereleases without a constraint",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: tmplTagLatest,
+						Usage: "Custom output format of the tag",
 					},
 					&cli.BoolFlag{
-						Name:  "xml",
+						Name:  "json",
 						Value: false,
-						Usage: "Print in XML format",
+						Usage: "Print the tag in JSON format",
 					},
 				},
 				Action: func(c *cli.Context) error {
-					return Handle(c, TagList)
+					return Handle(c, TagLatest)
 				},
 			},
 			{
-				Name:      "show",
-				Usage:     "Display a tag",
+				Name:      "prune",
+				Usage:     "Prune tags by retention rules",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
-						Name:  "id, i",
+						Name:  "repo",
 						Value: "",
-						Usage: "Tag ID or slug to show",
+						Usage: "Repo ID or slug to prune tags for",
 					},
 					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplTagShow,
-						Usage: "Custom output format",
+						Name:  "org",
+						Value: "",
+						Usage: "Org ID or slug to prune tags for",
+					},
+					&cli.IntFlag{
+						Name:  "keep-last",
+						Value: 0,
+						Usage: "Keep the newest tags per repo",
+					},
+					&cli.StringFlag{
+						Name:  "older-than",
+						Value: "",
+						Usage: "Only prune tags older than this age, like 30d",
+					},
+					&cli.StringFlag{
+						Name:  "match",
+						Value: "",
+						Usage: "Only prune tags matching this regex",
+					},
+					&cli.StringFlag{
+						Name:  "exclude",
+						Value: "",
+						Usage: "Never prune tags matching this regex",
 					},
 					&cli.BoolFlag{
-						Name:  "json",
+						Name:  "keep-semver-latest",
 						Value: false,
-						Usage: "Print in JSON format",
+						Usage: "Keep the latest semver release per repo",
 					},
 					&cli.BoolFlag{
-						Name:  "xml",
+						Name:  "dry-run",
+						Value: true,
+						Usage: "Only print the tags which would be deleted, default unless --yes is used",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
 						Value: false,
-						Usage: "Print in XML format",
+						Usage: "Really delete the tags",
 					},
-				},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Value: 4,
+						Usage: "Number of parallel deletions",
+					},
+					&cli.BoolFlag{
+						Name:  "show-kept",
+						Value: false,
+						Usage: "Include the kept tags within the report",
+					},
+				}, outputFlags(tmplTagPrune)...),
 				Action: func(c *cli.Context) error {
-					return Handle(c, TagShow)
+					return Handle(c, TagPrune)
 				},
 			},
 			{
@@ -115,113 +267,114 @@ func TagList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
+	if val := c.String("repo"); val != "" {
+		id, err := ResolveID(client, "repo", val)
 
 		if err != nil {
 			return err
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
+		scoped := make([]*umschlag.Tag, 0, len(records))
 
-		if err != nil {
-			return err
+		for _, record := range records {
+			if record.RepoID == id {
+				scoped = append(scoped, record)
+			}
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
+		records = scoped
 	}
 
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
+	return RenderList(c, records, tableTagList)
+}
 
-	if err != nil {
-		return err
+// TagLatest provides the sub-command to show the newest semver tag of a repo,
+// by default it only prints the tag name to be used within scripts.
+func TagLatest(c *cli.Context, client umschlag.ClientAPI) error {
+	if c.String("repo") == "" {
+		return fmt.Errorf("you must provide a repo id or slug")
 	}
 
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
+	var constraint *semver.Constraints
+
+	if val := c.String("constraint"); val != "" {
+		parsed, err := semver.NewConstraint(val)
 
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid constraint %s: %s", val, err)
 		}
-	}
 
-	return nil
-}
+		constraint = parsed
+	}
 
-// TagShow provides the sub-command to show tag details.
-func TagShow(c *cli.Context, client umschlag.ClientAPI) error {
-	record, err := client.TagGet(
-		GetIdentifierParam(c),
-	)
+	id, err := ResolveID(client, "repo", c.String("repo"))
 
 	if err != nil {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
+	records, err := client.TagList()
+
+	if err != nil {
+		return err
 	}
 
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
+	var (
+		latest  *umschlag.Tag
+		version *semver.Version
+	)
+
+	for _, record := range records {
+		if record.RepoID != id {
+			continue
+		}
+
+		current, err := semver.NewVersion(record.Name)
 
 		if err != nil {
-			return err
+			continue
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
+		if constraint != nil {
+			if !constraint.Check(current) {
+				continue
+			}
+		} else if current.Prerelease() != "" && !c.Bool("prerelease") {
+			continue
+		}
 
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
+		if version == nil || current.GreaterThan(version) {
+			latest = record
+			version = current
+		}
+	}
 
-		if err != nil {
-			return err
+	if latest == nil {
+		if constraint != nil {
+			return fmt.Errorf("no tag matches constraint %s", c.String("constraint"))
 		}
 
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
+		return fmt.Errorf("no semver tag found")
 	}
 
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
+	if c.Bool("json") {
+		return renderJSON(os.Stdout, latest)
+	}
+
+	return renderTemplate(os.Stdout, []interface{}{latest}, c.String("format"))
+}
+
+// TagShow provides the sub-command to show tag details.
+func TagShow(c *cli.Context, client umschlag.ClientAPI) error {
+	record, err := client.TagGet(
+		GetIdentifierParam(c),
 	)
 
 	if err != nil {
 		return err
 	}
 
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableTagShow)
 }
 
 // TagDelete provides the sub-command to delete a tag.
@@ -1,24 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"text/template"
 
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplTeamList represents a row within user listing.
-var tmplTeamList = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplTeamList = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .Name }}
 `
 
 // tmplTeamShow represents a user within details view.
-var tmplTeamShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplTeamShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Name: {{ .Name }}{{with .Users}}
 Users: {{ userlist . }}{{end}}{{with .Orgs}}
@@ -28,19 +25,55 @@ Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
 // tmplTeamUserList represents a row within team user listing.
-var tmplTeamUserList = "Slug: \x1b[33m{{ .User.Slug }} \x1b[0m" + `
+var tmplTeamUserList = `Slug: {{ highlight .User.Slug }}
 ID: {{ .User.ID }}
 Username: {{ .User.Username }}
 Permission: {{ .Perm }}
 `
 
 // tmplTeamOrgList represents a row within team org listing.
-var tmplTeamOrgList = "Slug: \x1b[33m{{ .Org.Slug }} \x1b[0m" + `
+var tmplTeamOrgList = `Slug: {{ highlight .Org.Slug }}
 ID: {{ .Org.ID }}
 Name: {{ .Org.Name }}
 Permission: {{ .Perm }}
 `
 
+// tableTeamList defines the columns within team listing.
+var tableTeamList = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+}
+
+// tableTeamShow defines the columns within team details view.
+var tableTeamShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "NAME", Value: `{{ .Name }}`},
+	{Title: "USERS", Value: `{{ with .Users }}{{ userlist . }}{{ end }}`},
+	{Title: "ORGS", Value: `{{ with .Orgs }}{{ orglist . }}{{ end }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
+// tableTeamUserList defines the columns within team user listing.
+var tableTeamUserList = []outputColumn{
+	{Title: "ID", Value: `{{ .User.ID }}`},
+	{Title: "SLUG", Value: `{{ .User.Slug }}`},
+	{Title: "USERNAME", Value: `{{ .User.Username }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+}
+
+// tableTeamOrgList defines the columns within team org listing.
+var tableTeamOrgList = []outputColumn{
+	{Title: "ID", Value: `{{ .Org.ID }}`},
+	{Title: "SLUG", Value: `{{ .Org.Slug }}`},
+	{Title: "NAME", Value: `{{ .Org.Name }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+}
+
 // Team provides the sub-command for the team API.
 func Team() *cli.Command {
 	return &cli.Command{
@@ -52,23 +85,7 @@ func Team() *cli.Command {
 				Aliases:   []string{"ls"},
 				Usage:     "List all teams",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplTeamList,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				Flags:     listFlags(tmplTeamList),
 				Action: func(c *cli.Context) error {
 					return Handle(c, TeamList)
 				},
@@ -77,28 +94,13 @@ func Team() *cli.Command {
 				Name:      "show",
 				Usage:     "Display a team",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "id, i",
 						Value: "",
 						Usage: "Team ID or slug to show",
 					},
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplTeamShow,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				}, outputFlags(tmplTeamShow)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, TeamShow)
 				},
@@ -173,28 +175,13 @@ func Team() *cli.Command {
 						Aliases:   []string{"ls"},
 						Usage:     "List assigned users",
 						ArgsUsage: " ",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "id, i",
 								Value: "",
 								Usage: "Team ID or slug to list users",
 							},
-							&cli.StringFlag{
-								Name:  "format",
-								Value: tmplTeamUserList,
-								Usage: "Custom output format",
-							},
-							&cli.BoolFlag{
-								Name:  "json",
-								Value: false,
-								Usage: "Print in JSON format",
-							},
-							&cli.BoolFlag{
-								Name:  "xml",
-								Value: false,
-								Usage: "Print in XML format",
-							},
-						},
+						}, listFlags(tmplTeamUserList)...),
 						Action: func(c *cli.Context) error {
 							return Handle(c, TeamUserList)
 						},
@@ -281,28 +268,13 @@ func Team() *cli.Command {
 						Aliases:   []string{"ls"},
 						Usage:     "List assigned orgs",
 						ArgsUsage: " ",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "id, i",
 								Value: "",
 								Usage: "Team ID or slug to list orgs",
 							},
-							&cli.StringFlag{
-								Name:  "format",
-								Value: tmplTeamOrgList,
-								Usage: "Custom output format",
-							},
-							&cli.BoolFlag{
-								Name:  "json",
-								Value: false,
-								Usage: "Print in JSON format",
-							},
-							&cli.BoolFlag{
-								Name:  "xml",
-								Value: false,
-								Usage: "Print in XML format",
-							},
-						},
+						}, listFlags(tmplTeamOrgList)...),
 						Action: func(c *cli.Context) error {
 							return Handle(c, TeamOrgList)
 						},
@@ -392,60 +364,7 @@ func TeamList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableTeamList)
 }
 
 // TeamShow provides the sub-command to show team details.
@@ -458,47 +377,7 @@ func TeamShow(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableTeamShow)
 }
 
 // TeamDelete provides the sub-command to delete a team.
@@ -592,60 +471,7 @@ func TeamUserList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableTeamUserList)
 }
 
 // TeamUserAppend provides the sub-command to append a user to the team.
@@ -713,60 +539,7 @@ func TeamOrgList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableTeamOrgList)
 }
 
 // TeamOrgAppend provides the sub-command to append a org to the team.
@@ -1,24 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"os"
-	"text/template"
 
 	"github.com/umschlag/umschlag-go/umschlag"
 	"gopkg.in/urfave/cli.v2"
 )
 
 // tmplUserList represents a row within user listing.
-var tmplUserList = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplUserList = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Username: {{ .Username }}
 `
 
 // tmplUserShow represents a user within details view.
-var tmplUserShow = "Slug: \x1b[33m{{ .Slug }} \x1b[0m" + `
+var tmplUserShow = `Slug: {{ highlight .Slug }}
 ID: {{ .ID }}
 Username: {{ .Username }}
 Email: {{ .Email }}
@@ -31,19 +28,61 @@ Updated: {{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}
 `
 
 // tmplUserTeamList represents a row within user team listing.
-var tmplUserTeamList = "Slug: \x1b[33m{{ .User.Slug }} \x1b[0m" + `
-ID: {{ .User.ID }}
-Name: {{ .User.Name }}
+var tmplUserTeamList = `Slug: {{ highlight .Team.Slug }}
+ID: {{ .Team.ID }}
+Name: {{ .Team.Name }}
 Permission: {{ .Perm }}
 `
 
 // tmplUserOrgList represents a row within user org listing.
-var tmplUserOrgList = "Slug: \x1b[33m{{ .Org.Slug }} \x1b[0m" + `
+var tmplUserOrgList = `Slug: {{ highlight .Org.Slug }}
 ID: {{ .Org.ID }}
 Name: {{ .Org.Name }}
 Permission: {{ .Perm }}
 `
 
+// tableUserList defines the columns within user listing.
+var tableUserList = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "USERNAME", Value: `{{ .Username }}`},
+	{Title: "EMAIL", Value: `{{ .Email }}`, Wide: true},
+	{Title: "ACTIVE", Value: `{{ .Active }}`, Wide: true},
+	{Title: "ADMIN", Value: `{{ .Admin }}`, Wide: true},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`, Wide: true},
+}
+
+// tableUserShow defines the columns within user details view.
+var tableUserShow = []outputColumn{
+	{Title: "ID", Value: `{{ .ID }}`},
+	{Title: "SLUG", Value: `{{ .Slug }}`},
+	{Title: "USERNAME", Value: `{{ .Username }}`},
+	{Title: "EMAIL", Value: `{{ .Email }}`},
+	{Title: "ACTIVE", Value: `{{ .Active }}`},
+	{Title: "ADMIN", Value: `{{ .Admin }}`},
+	{Title: "TEAMS", Value: `{{ with .Teams }}{{ teamlist . }}{{ end }}`},
+	{Title: "ORGS", Value: `{{ with .Orgs }}{{ orglist . }}{{ end }}`},
+	{Title: "CREATED", Value: `{{ .CreatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+	{Title: "UPDATED", Value: `{{ .UpdatedAt.Format "Mon Jan _2 15:04:05 MST 2006" }}`},
+}
+
+// tableUserTeamList defines the columns within user team listing.
+var tableUserTeamList = []outputColumn{
+	{Title: "ID", Value: `{{ .Team.ID }}`},
+	{Title: "SLUG", Value: `{{ .Team.Slug }}`},
+	{Title: "NAME", Value: `{{ .Team.Name }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+}
+
+// tableUserOrgList defines the columns within user org listing.
+var tableUserOrgList = []outputColumn{
+	{Title: "ID", Value: `{{ .Org.ID }}`},
+	{Title: "SLUG", Value: `{{ .Org.Slug }}`},
+	{Title: "NAME", Value: `{{ .Org.Name }}`},
+	{Title: "PERMISSION", Value: `{{ .Perm }}`},
+}
+
 // User provides the sub-command for the user API.
 func User() *cli.Command {
 	return &cli.Command{
@@ -55,23 +94,7 @@ func User() *cli.Command {
 				Aliases:   []string{"ls"},
 				Usage:     "List all users",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplUserList,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				Flags:     listFlags(tmplUserList),
 				Action: func(c *cli.Context) error {
 					return Handle(c, UserList)
 				},
@@ -80,28 +103,13 @@ func User() *cli.Command {
 				Name:      "show",
 				Usage:     "Display a user",
 				ArgsUsage: " ",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "id, i",
 						Value: "",
 						Usage: "User ID or slug to show",
 					},
-					&cli.StringFlag{
-						Name:  "format",
-						Value: tmplUserShow,
-						Usage: "Custom output format",
-					},
-					&cli.BoolFlag{
-						Name:  "json",
-						Value: false,
-						Usage: "Print in JSON format",
-					},
-					&cli.BoolFlag{
-						Name:  "xml",
-						Value: false,
-						Usage: "Print in XML format",
-					},
-				},
+				}, outputFlags(tmplUserShow)...),
 				Action: func(c *cli.Context) error {
 					return Handle(c, UserShow)
 				},
@@ -219,6 +227,57 @@ func User() *cli.Command {
 					return Handle(c, UserCreate)
 				},
 			},
+			{
+				Name:      "import",
+				Usage:     "Create users with memberships from a CSV file",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Value:   "",
+						Usage:   "CSV with username, slug, email, password, admin, active, teams and orgs",
+					},
+					&cli.StringFlag{
+						Name:  "result",
+						Value: "",
+						Usage: "Path for the result CSV, defaults to <file>-result.csv",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return Handle(c, UserImport)
+				},
+			},
+			{
+				Name:      "offboard",
+				Usage:     "Remove all memberships and block a user",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "id, i",
+						Value: "",
+						Usage: "User ID or slug to offboard",
+					},
+					&cli.StringFlag{
+						Name:  "transfer-to",
+						Value: "",
+						Usage: "User ID, slug or username to take over the last ownerships",
+					},
+					&cli.BoolFlag{
+						Name:  "delete",
+						Value: false,
+						Usage: "Delete the user after blocking it",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Value: false,
+						Usage: "Only print what would be changed",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return Handle(c, UserOffboard)
+				},
+			},
 			{
 				Name:  "team",
 				Usage: "Team assignments",
@@ -228,28 +287,13 @@ func User() *cli.Command {
 						Aliases:   []string{"ls"},
 						Usage:     "List assigned teams",
 						ArgsUsage: " ",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "id, i",
 								Value: "",
 								Usage: "User ID or slug to list teams",
 							},
-							&cli.StringFlag{
-								Name:  "format",
-								Value: tmplUserTeamList,
-								Usage: "Custom output format",
-							},
-							&cli.BoolFlag{
-								Name:  "json",
-								Value: false,
-								Usage: "Print in JSON format",
-							},
-							&cli.BoolFlag{
-								Name:  "xml",
-								Value: false,
-								Usage: "Print in XML format",
-							},
-						},
+						}, listFlags(tmplUserTeamList)...),
 						Action: func(c *cli.Context) error {
 							return Handle(c, UserTeamList)
 						},
@@ -336,28 +380,13 @@ func User() *cli.Command {
 						Aliases:   []string{"ls"},
 						Usage:     "List assigned orgs",
 						ArgsUsage: " ",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "id, i",
 								Value: "",
 								Usage: "User ID or slug to list orgs",
 							},
-							&cli.StringFlag{
-								Name:  "format",
-								Value: tmplUserOrgList,
-								Usage: "Custom output format",
-							},
-							&cli.BoolFlag{
-								Name:  "json",
-								Value: false,
-								Usage: "Print in JSON format",
-							},
-							&cli.BoolFlag{
-								Name:  "xml",
-								Value: false,
-								Usage: "Print in XML format",
-							},
-						},
+						}, listFlags(tmplUserOrgList)...),
 						Action: func(c *cli.Context) error {
 							return Handle(c, UserOrgList)
 						},
@@ -447,60 +476,7 @@ func UserList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableUserList)
 }
 
 // UserShow provides the sub-command to show user details.
@@ -513,47 +489,7 @@ func UserShow(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(record, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(os.Stdout, record)
+	return RenderRecord(c, record, tableUserShow)
 }
 
 // UserDelete provides the sub-command to delete a user.
@@ -721,60 +657,7 @@ func UserTeamList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableUserTeamList)
 }
 
 // UserTeamAppend provides the sub-command to append a team to the user.
@@ -842,60 +725,7 @@ func UserOrgList(c *cli.Context, client umschlag.ClientAPI) error {
 		return err
 	}
 
-	if c.IsSet("json") && c.IsSet("xml") {
-		return fmt.Errorf("conflict, you can only use json or xml at once")
-	}
-
-	if c.Bool("xml") {
-		res, err := xml.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if c.Bool("json") {
-		res, err := json.MarshalIndent(records, "", "  ")
-
-		if err != nil {
-			return err
-		}
-
-		fmt.Fprintf(os.Stdout, "%s\n", res)
-		return nil
-	}
-
-	if len(records) == 0 {
-		fmt.Fprintf(os.Stderr, "Empty result\n")
-		return nil
-	}
-
-	tmpl, err := template.New(
-		"_",
-	).Funcs(
-		globalFuncMap,
-	).Funcs(
-		sprigFuncMap,
-	).Parse(
-		fmt.Sprintf("%s\n", c.String("format")),
-	)
-
-	if err != nil {
-		return err
-	}
-
-	for _, record := range records {
-		err := tmpl.Execute(os.Stdout, record)
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return RenderList(c, records, tableUserOrgList)
 }
 
 // UserOrgAppend provides the sub-command to append a org to the user.
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// userImportColumns defines the supported columns of the users CSV.
+var userImportColumns = []string{
+	"username",
+	"slug",
+	"email",
+	"password",
+	"admin",
+	"active",
+	"teams",
+	"orgs",
+}
+
+// userImportRow represents a validated row of the users CSV.
+type userImportRow struct {
+	Line     int
+	Username string
+	Slug     string
+	Email    string
+	Password string
+	Admin    bool
+	Active   bool
+	Teams    map[string]string
+	Orgs     map[string]string
+
+	existing  *umschlag.User
+	ignored   []string
+	generated bool
+	status    string
+	err       error
+}
+
+// lineReader counts the lines consumed by the CSV reader, it never returns
+// more than a single line per read so the CSV reader can't read ahead.
+type lineReader struct {
+	r    *bufio.Reader
+	line int
+	last byte
+}
+
+// Read implements the io.Reader interface.
+func (l *lineReader) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		b, err := l.r.ReadByte()
+
+		if err != nil {
+			return n, err
+		}
+
+		p[n] = b
+		n++
+		l.last = b
+
+		if b == '\n' {
+			l.line++
+			break
+		}
+	}
+
+	return n, nil
+}
+
+// end returns the line number of the last consumed line.
+func (l *lineReader) end() int {
+	if l.last == '\n' {
+		return l.line
+	}
+
+	return l.line + 1
+}
+
+// readUserImport parses the users CSV, the first line must name the columns.
+// The line of a row is the line it starts on, including quoted line breaks.
+func readUserImport(r io.Reader) ([]map[string]string, []int, error) {
+	counter := &lineReader{r: bufio.NewReader(r)}
+	reader := csv.NewReader(counter)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %s", err)
+	}
+
+	known := map[string]bool{}
+
+	for _, column := range userImportColumns {
+		known[column] = true
+	}
+
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+
+		if !known[header[i]] {
+			return nil, nil, fmt.Errorf("unknown column %q, can be %s", column, strings.Join(userImportColumns, ", "))
+		}
+	}
+
+	var (
+		rows  = []map[string]string{}
+		lines = []int{}
+	)
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line := counter.end()
+		row := map[string]string{}
+
+		for i, column := range header {
+			line -= strings.Count(record[i], "\n")
+			row[column] = strings.TrimSpace(record[i])
+		}
+
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+
+	return rows, lines, nil
+}
+
+// parseMemberships parses a list like "devs:admin;ops", the permission
+// defaults to user.
+func parseMemberships(val string) (map[string]string, error) {
+	result := map[string]string{}
+
+	for _, entry := range strings.Split(val, ";") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		slug, perm := entry, "user"
+
+		if pos := strings.LastIndex(entry, ":"); pos >= 0 {
+			slug, perm = strings.TrimSpace(entry[:pos]), strings.TrimSpace(entry[pos+1:])
+		}
+
+		if !validPerm(perm) {
+			return nil, fmt.Errorf("invalid permission %q for %s, can be user, admin or owner", perm, slug)
+		}
+
+		result[slug] = perm
+	}
+
+	return result, nil
+}
+
+// parseBool parses an optional boolean column.
+func parseBool(column, val string, def bool) (bool, error) {
+	if val == "" {
+		return def, nil
+	}
+
+	res, err := strconv.ParseBool(val)
+
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q, must be true or false", column, val)
+	}
+
+	return res, nil
+}
+
+// validateUserImport checks all rows against each other and the server, all
+// problems are collected to report them at once.
+func validateUserImport(client umschlag.ClientAPI, rows []map[string]string, lines []int) ([]*userImportRow, []string, error) {
+	users, err := client.UserList()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teams, err := client.TeamList()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orgs, err := client.OrgList()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		knownTeams = map[string]bool{}
+		knownOrgs  = map[string]bool{}
+		seen       = map[string]int{}
+		slugs      = map[string]int{}
+		result     = []*userImportRow{}
+		problems   = []string{}
+	)
+
+	for _, team := range teams {
+		knownTeams[team.Slug] = true
+	}
+
+	for _, org := range orgs {
+		knownOrgs[org.Slug] = true
+	}
+
+	for i, row := range rows {
+		record := &userImportRow{
+			Line:     lines[i],
+			Username: row["username"],
+			Slug:     row["slug"],
+			Email:    row["email"],
+			Password: row["password"],
+		}
+
+		fail := func(format string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf("line %d: %s", record.Line, fmt.Sprintf(format, args...)))
+		}
+
+		if record.Username == "" {
+			fail("username is required")
+		} else if line, ok := seen[record.Username]; ok {
+			fail("username %s already used on line %d", record.Username, line)
+		} else {
+			seen[record.Username] = record.Line
+		}
+
+		for _, user := range users {
+			if user.Username == record.Username {
+				record.existing = user
+				break
+			}
+		}
+
+		if record.Slug != "" {
+			if line, ok := slugs[record.Slug]; ok {
+				fail("slug %s already used on line %d", record.Slug, line)
+			} else {
+				slugs[record.Slug] = record.Line
+			}
+
+			for _, user := range users {
+				if user.Slug == record.Slug && user.Username != record.Username {
+					fail("slug %s already used by user %s", record.Slug, user.Username)
+				}
+			}
+		}
+
+		if record.existing == nil && !strings.Contains(record.Email, "@") {
+			fail("valid email is required")
+		}
+
+		if record.Admin, err = parseBool("admin", row["admin"], false); err != nil {
+			fail("%s", err)
+		}
+
+		if record.Active, err = parseBool("active", row["active"], true); err != nil {
+			fail("%s", err)
+		}
+
+		if record.existing != nil {
+			record.ignored = ignoredColumns(record, row)
+		}
+
+		if record.Teams, err = parseMemberships(row["teams"]); err != nil {
+			fail("%s", err)
+		}
+
+		for slug := range record.Teams {
+			if !knownTeams[slug] {
+				fail("team %s doesn't exist", slug)
+			}
+		}
+
+		if record.Orgs, err = parseMemberships(row["orgs"]); err != nil {
+			fail("%s", err)
+		}
+
+		for slug := range record.Orgs {
+			if !knownOrgs[slug] {
+				fail("org %s doesn't exist", slug)
+			}
+		}
+
+		result = append(result, record)
+	}
+
+	return result, problems, nil
+}
+
+// ignoredColumns lists the columns of an existing user differing from the
+// server, only missing memberships are added to existing users.
+func ignoredColumns(record *userImportRow, row map[string]string) []string {
+	var (
+		user    = record.existing
+		ignored = []string{}
+	)
+
+	if record.Slug != "" && record.Slug != user.Slug {
+		ignored = append(ignored, "slug")
+	}
+
+	if record.Email != "" && record.Email != user.Email {
+		ignored = append(ignored, "email")
+	}
+
+	if record.Password != "" {
+		ignored = append(ignored, "password")
+	}
+
+	if row["admin"] != "" && record.Admin != user.Admin {
+		ignored = append(ignored, "admin")
+	}
+
+	if row["active"] != "" && record.Active != user.Active {
+		ignored = append(ignored, "active")
+	}
+
+	return ignored
+}
+
+// importUser creates the user of the row if it doesn't exist yet and appends
+// all missing memberships.
+func importUser(client umschlag.ClientAPI, row *userImportRow) error {
+	var (
+		id      string
+		teams   = map[string]bool{}
+		orgs    = map[string]bool{}
+		changed = false
+	)
+
+	if row.existing != nil {
+		id = strconv.FormatInt(row.existing.ID, 10)
+
+		current, err := client.UserTeamList(umschlag.UserTeamParams{User: id})
+
+		if err != nil {
+			return err
+		}
+
+		for _, member := range current {
+			if member.Team == nil {
+				return fmt.Errorf("team membership of user %s without team", row.Username)
+			}
+
+			teams[member.Team.Slug] = true
+		}
+
+		assigned, err := client.UserOrgList(umschlag.UserOrgParams{User: id})
+
+		if err != nil {
+			return err
+		}
+
+		for _, member := range assigned {
+			if member.Org == nil {
+				return fmt.Errorf("org membership of user %s without org", row.Username)
+			}
+
+			orgs[member.Org.Slug] = true
+		}
+	} else {
+		generated := row.Password == ""
+
+		if generated {
+			password, err := randomPassword()
+
+			if err != nil {
+				return err
+			}
+
+			row.Password = password
+		}
+
+		record, err := client.UserPost(&umschlag.User{
+			Slug:     row.Slug,
+			Username: row.Username,
+			Email:    row.Email,
+			Password: row.Password,
+			Admin:    row.Admin,
+			Active:   row.Active,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		id = strconv.FormatInt(record.ID, 10)
+		row.generated = generated
+		changed = true
+	}
+
+	for _, slug := range sortedKeys(row.Teams) {
+		if teams[slug] {
+			continue
+		}
+
+		if err := client.UserTeamAppend(umschlag.UserTeamParams{User: id, Team: slug, Perm: row.Teams[slug]}); err != nil {
+			return fmt.Errorf("failed to append team %s: %s", slug, err)
+		}
+
+		changed = true
+	}
+
+	for _, slug := range sortedKeys(row.Orgs) {
+		if orgs[slug] {
+			continue
+		}
+
+		if err := client.UserOrgAppend(umschlag.UserOrgParams{User: id, Org: slug, Perm: row.Orgs[slug]}); err != nil {
+			return fmt.Errorf("failed to append org %s: %s", slug, err)
+		}
+
+		changed = true
+	}
+
+	switch {
+	case row.existing == nil:
+		row.status = "created"
+	case changed:
+		row.status = "updated"
+	default:
+		row.status = "unchanged"
+	}
+
+	return nil
+}
+
+// writeUserImportResult writes the per-row status including generated
+// passwords, that's why the file is only readable by the owner.
+func writeUserImportResult(path string, rows []*userImportRow) error {
+	buf := &strings.Builder{}
+	writer := csv.NewWriter(buf)
+
+	writer.Write([]string{"line", "username", "status", "password", "error"})
+
+	for _, row := range rows {
+		var (
+			password string
+			message  string
+		)
+
+		if row.generated {
+			password = row.Password
+		}
+
+		if row.err != nil {
+			message = row.err.Error()
+		}
+
+		writer.Write([]string{
+			strconv.Itoa(row.Line),
+			row.Username,
+			row.status,
+			password,
+			message,
+		})
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	return writeSecretFile(path, []byte(buf.String()))
+}
+
+// UserImport provides the sub-command to create users from a CSV file.
+func UserImport(c *cli.Context, client umschlag.ClientAPI) error {
+	path := c.String("file")
+
+	if path == "" {
+		return fmt.Errorf("you must provide a CSV file")
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	raw, lines, err := readUserImport(file)
+
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	rows, problems, err := validateUserImport(client, raw, lines)
+
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s\n", problem)
+		}
+
+		return fmt.Errorf("%s contains %d problems, nothing has been imported", path, len(problems))
+	}
+
+	result := c.String("result")
+
+	if result == "" {
+		result = strings.TrimSuffix(path, filepath.Ext(path)) + "-result.csv"
+	}
+
+	for _, row := range rows {
+		if len(row.ignored) > 0 {
+			fmt.Fprintf(
+				os.Stderr,
+				"line %d: ignoring %s for the existing user %s\n",
+				row.Line,
+				strings.Join(row.ignored, ", "),
+				row.Username,
+			)
+		}
+	}
+
+	failed := 0
+
+	for _, row := range rows {
+		if err := importUser(client, row); err != nil {
+			row.status = "failed"
+			row.err = err
+			failed++
+		}
+	}
+
+	if err := writeUserImportResult(result, rows); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"Imported %d of %d users, result written to %s\n",
+		len(rows)-failed,
+		len(rows),
+		result,
+	)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to import %d users", failed)
+	}
+
+	return nil
+}
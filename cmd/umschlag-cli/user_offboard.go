@@ -0,0 +1,403 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/umschlag/umschlag-go/umschlag"
+	"gopkg.in/urfave/cli.v2"
+)
+
+// offboardState represents the account and memberships of a user at a point
+// in time, used for the before and after report.
+type offboardState struct {
+	Active  bool
+	Deleted bool
+	Teams   []*umschlag.TeamUser
+	Orgs    []*umschlag.UserOrg
+}
+
+// fetchOffboardState loads the memberships of the user, memberships without
+// their team or org are rejected as the report and the plan rely on them.
+func fetchOffboardState(client umschlag.ClientAPI, user *umschlag.User) (*offboardState, error) {
+	id := strconv.FormatInt(user.ID, 10)
+
+	teams, err := client.UserTeamList(umschlag.UserTeamParams{User: id})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range teams {
+		if member.Team == nil {
+			return nil, fmt.Errorf("team membership of user %s without team", user.Username)
+		}
+	}
+
+	orgs, err := client.UserOrgList(umschlag.UserOrgParams{User: id})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range orgs {
+		if member.Org == nil {
+			return nil, fmt.Errorf("org membership of user %s without org", user.Username)
+		}
+	}
+
+	return &offboardState{
+		Active: user.Active,
+		Teams:  teams,
+		Orgs:   orgs,
+	}, nil
+}
+
+// Print writes the state of the account and all memberships.
+func (s *offboardState) Print(w io.Writer, title string, user *umschlag.User) {
+	var (
+		status = "active"
+		teams  = []string{}
+		orgs   = []string{}
+	)
+
+	switch {
+	case s.Deleted:
+		status = danger("deleted")
+	case !s.Active:
+		status = warning("blocked")
+	}
+
+	for _, member := range s.Teams {
+		teams = append(teams, fmt.Sprintf("%s (%s)", member.Team.Slug, member.Perm))
+	}
+
+	for _, member := range s.Orgs {
+		orgs = append(orgs, fmt.Sprintf("%s (%s)", member.Org.Slug, member.Perm))
+	}
+
+	if len(teams) == 0 {
+		teams = append(teams, "none")
+	}
+
+	if len(orgs) == 0 {
+		orgs = append(orgs, "none")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintf(tw, "%s:\n", title)
+	fmt.Fprintf(tw, "  User:\t%s (%s)\n", highlight(user.Username), status)
+	fmt.Fprintf(tw, "  Teams:\t%s\n", strings.Join(teams, ", "))
+	fmt.Fprintf(tw, "  Orgs:\t%s\n", strings.Join(orgs, ", "))
+
+	tw.Flush()
+}
+
+// lastOwnerships returns the orgs and teams where the user is the only owner.
+// Org ownership is resolved like the access check, which includes the owners
+// granted through teams.
+func lastOwnerships(live *liveState, user *umschlag.User) ([]string, []string, error) {
+	var (
+		orgs  = []string{}
+		teams = []string{}
+	)
+
+	access, err := resolveAccess(live)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, org := range live.orgs {
+		if effectivePerm(access[user.Username][org.Slug]) != "owner" {
+			continue
+		}
+
+		last := true
+
+		for username, grants := range access {
+			if username != user.Username && effectivePerm(grants[org.Slug]) == "owner" {
+				last = false
+				break
+			}
+		}
+
+		if last {
+			orgs = append(orgs, org.Slug)
+		}
+	}
+
+	for _, team := range live.teams {
+		owner, others := false, false
+
+		for _, member := range live.teamUsers[team.ID] {
+			if member.Perm != "owner" {
+				continue
+			}
+
+			if member.User.ID == user.ID {
+				owner = true
+			} else {
+				others = true
+			}
+		}
+
+		if owner && !others {
+			teams = append(teams, team.Slug)
+		}
+	}
+
+	return orgs, teams, nil
+}
+
+// planOffboard transfers the last ownerships of orgs and teams, removes all
+// memberships and blocks or deletes the account.
+func planOffboard(client umschlag.ClientAPI, live *liveState, user, target *umschlag.User, orgs, teams []string, before *offboardState, remove bool) *Plan {
+	var (
+		plan = &Plan{}
+		id   = strconv.FormatInt(user.ID, 10)
+	)
+
+	if target != nil {
+		targetID := strconv.FormatInt(target.ID, 10)
+
+		for _, slug := range orgs {
+			org, current := slug, ""
+
+			for _, member := range live.userOrgs[target.ID] {
+				if member.Org.Slug == org {
+					current = member.Perm
+				}
+			}
+
+			change := &planChange{
+				Action: "create",
+				Kind:   "user-org",
+				Name:   target.Username + "/" + org,
+				Fields: []planField{{Name: "perm", New: "owner"}},
+				run: func() error {
+					return client.UserOrgAppend(umschlag.UserOrgParams{User: targetID, Org: org, Perm: "owner"})
+				},
+			}
+
+			if current != "" {
+				change.Action = "update"
+				change.Fields = []planField{{Name: "perm", Old: current, New: "owner"}}
+				change.run = func() error {
+					return client.UserOrgPerm(umschlag.UserOrgParams{User: targetID, Org: org, Perm: "owner"})
+				}
+			}
+
+			plan.Changes = append(plan.Changes, change)
+		}
+
+		for _, slug := range teams {
+			team, current := slug, ""
+
+			for _, record := range live.teams {
+				if record.Slug != team {
+					continue
+				}
+
+				for _, member := range live.teamUsers[record.ID] {
+					if member.User.ID == target.ID {
+						current = member.Perm
+					}
+				}
+			}
+
+			change := &planChange{
+				Action: "create",
+				Kind:   "team-user",
+				Name:   team + "/" + target.Username,
+				Fields: []planField{{Name: "perm", New: "owner"}},
+				run: func() error {
+					return client.UserTeamAppend(umschlag.UserTeamParams{User: targetID, Team: team, Perm: "owner"})
+				},
+			}
+
+			if current != "" {
+				change.Action = "update"
+				change.Fields = []planField{{Name: "perm", Old: current, New: "owner"}}
+				change.run = func() error {
+					return client.UserTeamPerm(umschlag.UserTeamParams{User: targetID, Team: team, Perm: "owner"})
+				}
+			}
+
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	for _, member := range before.Teams {
+		team := member.Team.Slug
+
+		plan.Changes = append(plan.Changes, &planChange{
+			Action: "delete",
+			Kind:   "team-user",
+			Name:   team + "/" + user.Username,
+			run: func() error {
+				return client.UserTeamDelete(umschlag.UserTeamParams{User: id, Team: team})
+			},
+		})
+	}
+
+	for _, member := range before.Orgs {
+		org := member.Org.Slug
+
+		plan.Changes = append(plan.Changes, &planChange{
+			Action: "delete",
+			Kind:   "user-org",
+			Name:   user.Username + "/" + org,
+			run: func() error {
+				return client.UserOrgDelete(umschlag.UserOrgParams{User: id, Org: org})
+			},
+		})
+	}
+
+	if user.Active {
+		plan.Changes = append(plan.Changes, &planChange{
+			Action: "update",
+			Kind:   "user",
+			Name:   user.Username,
+			Fields: []planField{{Name: "active", Old: "true", New: "false"}},
+			run: func() error {
+				record := *user
+				record.Active = false
+
+				_, err := client.UserPatch(&record)
+				return err
+			},
+		})
+	}
+
+	if remove {
+		plan.Changes = append(plan.Changes, &planChange{
+			Action: "delete",
+			Kind:   "user",
+			Name:   user.Username,
+			run: func() error {
+				return client.UserDelete(id)
+			},
+		})
+	}
+
+	return plan
+}
+
+// UserOffboard provides the sub-command to offboard a user. The last
+// ownerships of orgs and teams are transferred first, afterwards all
+// memberships get removed and the account gets blocked or deleted.
+func UserOffboard(c *cli.Context, client umschlag.ClientAPI) error {
+	user, err := client.UserGet(
+		GetIdentifierParam(c),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	live, err := fetchLiveState(client)
+
+	if err != nil {
+		return err
+	}
+
+	before, err := fetchOffboardState(client, user)
+
+	if err != nil {
+		return err
+	}
+
+	var target *umschlag.User
+
+	if val := c.String("transfer-to"); val != "" {
+		if target = findAccessUser(live, val); target == nil {
+			return fmt.Errorf("user %s doesn't exist", val)
+		}
+
+		if target.ID == user.ID {
+			return fmt.Errorf("can't transfer ownerships to the offboarded user")
+		}
+
+		if !target.Active {
+			return fmt.Errorf("can't transfer ownerships to the blocked user %s", target.Username)
+		}
+	}
+
+	// Resolving the ownerships rejects memberships of the live state without
+	// their user or org, the plan relies on them as well.
+	orgs, teams, err := lastOwnerships(live, user)
+
+	if err != nil {
+		return err
+	}
+
+	if target == nil && len(orgs)+len(teams) > 0 {
+		owned := []string{}
+
+		for _, org := range orgs {
+			owned = append(owned, "org "+org)
+		}
+
+		for _, team := range teams {
+			owned = append(owned, "team "+team)
+		}
+
+		return fmt.Errorf(
+			"%s is the last owner of %s, provide --transfer-to",
+			user.Username,
+			strings.Join(owned, ", "),
+		)
+	}
+
+	plan := planOffboard(client, live, user, target, orgs, teams, before, c.Bool("delete"))
+
+	before.Print(os.Stdout, "Before", user)
+
+	if len(plan.Changes) == 0 {
+		fmt.Fprintf(os.Stderr, "No changes, %s is already offboarded\n", user.Username)
+		return nil
+	}
+
+	fmt.Fprintln(os.Stdout)
+	plan.Print(os.Stdout)
+
+	after := &offboardState{
+		Deleted: c.Bool("delete"),
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Fprintln(os.Stdout)
+		after.Print(os.Stdout, "After", user)
+
+		fmt.Fprintf(os.Stderr, "Dry run, nothing has been changed\n")
+		return nil
+	}
+
+	if _, err := plan.Apply(); err != nil {
+		return err
+	}
+
+	if !after.Deleted {
+		record, err := client.UserGet(strconv.FormatInt(user.ID, 10))
+
+		if err != nil {
+			return err
+		}
+
+		if after, err = fetchOffboardState(client, record); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stdout)
+	after.Print(os.Stdout, "After", user)
+
+	fmt.Fprintf(os.Stderr, "Successfully offboarded %s\n", user.Username)
+	return nil
+}
@@ -1,14 +1,41 @@
 module github.com/umschlag/umschlag-cli
 
+go 1.27.1
+
 require (
-	github.com/Masterminds/goutils v1.1.0 // indirect
-	github.com/Masterminds/semver v1.4.2 // indirect
+	github.com/Masterminds/semver v1.4.2
 	github.com/Masterminds/sprig v2.18.0+incompatible
+	github.com/joho/godotenv v1.3.0
+	github.com/robfig/cron v1.2.0
+	github.com/umschlag/umschlag-go v0.0.0-20190506204856-1dc7dfad74d2
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	gopkg.in/urfave/cli.v2 v2.0.0-20180128182452-d3ae77c26ac8
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	cloud.google.com/go v0.34.0 // indirect
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/Masterminds/goutils v1.1.0 // indirect
+	github.com/campoy/embedmd v1.0.0 // indirect
+	github.com/golang/protobuf v1.2.0 // indirect
 	github.com/google/uuid v1.1.1 // indirect
+	github.com/hashicorp/go-version v1.0.0 // indirect
+	github.com/haya14busa/goverage v0.0.0-20180129164344-eec3514a20b5 // indirect
 	github.com/huandu/xstrings v1.2.0 // indirect
 	github.com/imdario/mergo v0.3.7 // indirect
-	github.com/joho/godotenv v1.3.0
+	github.com/jackspirou/syscerts v0.0.0-20160531025014-b68f5469dff1 // indirect
 	github.com/mitchellh/gox v1.0.1 // indirect
-	github.com/umschlag/umschlag-go v0.0.0-20190506204856-1dc7dfad74d2
-	gopkg.in/urfave/cli.v2 v2.0.0-20180128182452-d3ae77c26ac8
+	github.com/mitchellh/iochan v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/lint v0.0.0-20190409202823-959b441ac422 // indirect
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a // indirect
+	golang.org/x/oauth2 v0.0.0-20190402181905-9f3314589c9a // indirect
+	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
+	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a // indirect
+	golang.org/x/text v0.3.0 // indirect
+	golang.org/x/tools v0.0.0-20190506145303-2d16b83fe98c // indirect
+	google.golang.org/appengine v1.4.0 // indirect
+	gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
+	honnef.co/go/tools v0.0.0-20190418001031-e561f6794a2a // indirect
 )
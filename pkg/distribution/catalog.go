@@ -0,0 +1,91 @@
+package distribution
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// pageSize defines the number of entries requested per page.
+const pageSize = 100
+
+// linkPattern matches the next page within a Link header.
+var linkPattern = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// Catalog lists all repositories of the registry, it follows the pagination
+// of the registry until all pages have been fetched.
+func (c *Client) Catalog() ([]string, error) {
+	result := []string{}
+
+	err := c.paginate(
+		fmt.Sprintf("/v2/_catalog?n=%d", pageSize),
+		"registry:catalog:*",
+		func(resp *http.Response) error {
+			payload := struct {
+				Repositories []string `json:"repositories"`
+			}{}
+
+			if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+				return fmt.Errorf("failed to parse catalog: %s", err)
+			}
+
+			result = append(result, payload.Repositories...)
+			return nil
+		},
+	)
+
+	return result, err
+}
+
+// Tags lists all tags of the repository, it follows the pagination of the
+// registry until all pages have been fetched.
+func (c *Client) Tags(repo string) ([]string, error) {
+	result := []string{}
+
+	err := c.paginate(
+		fmt.Sprintf("/v2/%s/tags/list?n=%d", repo, pageSize),
+		pullScope(repo),
+		func(resp *http.Response) error {
+			payload := struct {
+				Tags []string `json:"tags"`
+			}{}
+
+			if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+				return fmt.Errorf("failed to parse tags: %s", err)
+			}
+
+			result = append(result, payload.Tags...)
+			return nil
+		},
+	)
+
+	return result, err
+}
+
+// paginate requests the path and all following pages referenced by the Link
+// header, every response gets passed to the handler.
+func (c *Client) paginate(path, scope string, handler func(*http.Response) error) error {
+	for path != "" {
+		resp, err := c.Do("GET", path, scope, nil)
+
+		if err != nil {
+			return err
+		}
+
+		err = handler(resp)
+		resp.Body.Close()
+
+		if err != nil {
+			return err
+		}
+
+		path = ""
+
+		if match := linkPattern.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			path = match[1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,424 @@
+package distribution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// challengePattern matches the parameters of an authentication challenge.
+var challengePattern = regexp.MustCompile(`([a-zA-Z]+)="([^"]*)"`)
+
+// Client is a minimal client for the Docker Registry HTTP API v2.
+type Client struct {
+	Host       string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+
+	mutex  sync.Mutex
+	base   string
+	tokens map[string]string
+}
+
+// NewClient initializes a client for the registry host, the host can include
+// a scheme, otherwise HTTPS is used with a plain HTTP fallback for loopback
+// addresses like the docker daemon does it.
+func NewClient(host, username, password string) *Client {
+	return &Client{
+		Host:     host,
+		Username: username,
+		Password: password,
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		tokens: map[string]string{},
+	}
+}
+
+// Manifest fetches the manifest for a tag or digest of the repository.
+func (c *Client) Manifest(repo, reference string) (*Manifest, error) {
+	resp, err := c.manifestRequest("GET", repo, reference)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Manifest{}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %s", err)
+	}
+
+	if result.MediaType == "" {
+		result.MediaType = contentType(resp)
+	}
+
+	result.Digest = resp.Header.Get("Docker-Content-Digest")
+	result.Size = int64(len(body))
+
+	if result.Digest == "" {
+		sum := sha256.Sum256(body)
+		result.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	return result, nil
+}
+
+// Digest resolves a tag to the digest of its manifest, it falls back to
+// fetch the manifest if the registry omits the digest header.
+func (c *Client) Digest(repo, reference string) (string, error) {
+	resp, err := c.manifestRequest("HEAD", repo, reference)
+
+	if err != nil {
+		return "", err
+	}
+
+	resp.Body.Close()
+
+	if val := resp.Header.Get("Docker-Content-Digest"); val != "" {
+		return val, nil
+	}
+
+	manifest, err := c.Manifest(repo, reference)
+
+	if err != nil {
+		return "", err
+	}
+
+	return manifest.Digest, nil
+}
+
+// Size sums up the manifest and all referenced blobs, the manifests of a
+// manifest list get fetched to include the layers of every platform.
+func (c *Client) Size(repo string, manifest *Manifest) (int64, error) {
+	if !manifest.IsList() {
+		return manifest.TotalSize(), nil
+	}
+
+	size := manifest.Size
+
+	for _, child := range manifest.Manifests {
+		image, err := c.Manifest(repo, child.Digest)
+
+		if err != nil {
+			return 0, err
+		}
+
+		childSize, err := c.Size(repo, image)
+
+		if err != nil {
+			return 0, err
+		}
+
+		size += childSize
+	}
+
+	return size, nil
+}
+
+// manifestRequest requests a manifest accepting all supported media types.
+func (c *Client) manifestRequest(method, repo, reference string) (*http.Response, error) {
+	header := http.Header{}
+
+	for _, val := range manifestTypes {
+		header.Add("Accept", val)
+	}
+
+	return c.Do(
+		method,
+		fmt.Sprintf("/v2/%s/manifests/%s", repo, reference),
+		pullScope(repo),
+		header,
+	)
+}
+
+// Do executes a request against the registry, it answers authentication
+// challenges and converts error responses into an Error.
+func (c *Client) Do(method, path, scope string, header http.Header) (*http.Response, error) {
+	resp, err := c.request(method, path, header, c.token(scope))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		auth, err := c.authorize(challenge, scope)
+
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = c.request(method, path, header, auth)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+
+	return resp, nil
+}
+
+// request sends a single request with the authorization header.
+func (c *Client) request(method, path string, header http.Header, auth string) (*http.Response, error) {
+	base, err := c.baseURL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	target := path
+
+	if !strings.Contains(path, "://") {
+		target = base + path
+	}
+
+	req, err := http.NewRequest(method, target, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, vals := range header {
+		for _, val := range vals {
+			req.Header.Add(key, val)
+		}
+	}
+
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
+// baseURL detects the scheme of the registry once.
+func (c *Client) baseURL() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.base != "" {
+		return c.base, nil
+	}
+
+	host := strings.TrimRight(c.Host, "/")
+
+	if strings.Contains(host, "://") {
+		c.base = host
+		return c.base, nil
+	}
+
+	c.base = "https://" + host
+
+	if !isLoopback(host) {
+		return c.base, nil
+	}
+
+	resp, err := c.HTTPClient.Get(c.base + "/v2/")
+
+	if err != nil {
+		c.base = "http://" + host
+		return c.base, nil
+	}
+
+	resp.Body.Close()
+	return c.base, nil
+}
+
+// token returns the cached authorization for the scope.
+func (c *Client) token(scope string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.tokens[scope]
+}
+
+// authorize answers the challenge with basic auth or by fetching a bearer
+// token from the realm, the result is cached per scope.
+func (c *Client) authorize(challenge, scope string) (string, error) {
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+
+	if len(parts) == 0 || parts[0] == "" {
+		return "", &Error{StatusCode: http.StatusUnauthorized, Message: "no authentication challenge provided"}
+	}
+
+	var auth string
+
+	switch strings.ToLower(parts[0]) {
+	case "basic":
+		if c.Username == "" {
+			return "", &Error{StatusCode: http.StatusUnauthorized, Message: "credentials required"}
+		}
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.SetBasicAuth(c.Username, c.Password)
+		auth = req.Header.Get("Authorization")
+	case "bearer":
+		params := map[string]string{}
+
+		if len(parts) == 2 {
+			for _, match := range challengePattern.FindAllStringSubmatch(parts[1], -1) {
+				params[strings.ToLower(match[1])] = match[2]
+			}
+		}
+
+		if params["scope"] == "" {
+			params["scope"] = scope
+		}
+
+		token, err := c.fetchToken(params)
+
+		if err != nil {
+			return "", err
+		}
+
+		auth = "Bearer " + token
+	default:
+		return "", fmt.Errorf("unsupported authentication scheme %s", parts[0])
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.tokens[scope] = auth
+	return auth, nil
+}
+
+// fetchToken requests a bearer token from the realm of the challenge.
+func (c *Client) fetchToken(params map[string]string) (string, error) {
+	if params["realm"] == "" {
+		return "", fmt.Errorf("authentication challenge without realm")
+	}
+
+	realm, err := url.Parse(params["realm"])
+
+	if err != nil {
+		return "", err
+	}
+
+	query := realm.Query()
+
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", parseError(resp)
+	}
+
+	result := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse token: %s", err)
+	}
+
+	if result.Token != "" {
+		return result.Token, nil
+	}
+
+	if result.AccessToken != "" {
+		return result.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("token endpoint returned no token")
+}
+
+// parseError converts an error response into an Error.
+func parseError(resp *http.Response) error {
+	result := &Error{
+		StatusCode: resp.StatusCode,
+	}
+
+	payload := struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if err := json.Unmarshal(body, &payload); err == nil && len(payload.Errors) > 0 {
+		result.Code = payload.Errors[0].Code
+		result.Message = payload.Errors[0].Message
+	}
+
+	return result
+}
+
+// contentType returns the media type of the response without parameters.
+func contentType(resp *http.Response) string {
+	return strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+}
+
+// pullScope returns the token scope to read from a repository.
+func pullScope(repo string) string {
+	return fmt.Sprintf("repository:%s:pull", repo)
+}
+
+// isLoopback checks if the host points to the local machine.
+func isLoopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
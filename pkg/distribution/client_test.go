@@ -0,0 +1,413 @@
+package distribution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testManifest defines a single manifest served by the fake registry.
+type testManifest struct {
+	mediaType string
+	body      []byte
+}
+
+// digest returns the content digest of the manifest.
+func (m *testManifest) digest() string {
+	sum := sha256.Sum256(m.body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testRegistry represents a fake registry implementing the manifest
+// endpoints, auth defines the challenge answered to anonymous requests.
+type testRegistry struct {
+	server    *httptest.Server
+	auth      string
+	username  string
+	password  string
+	token     string
+	noDigest  bool
+	manifests map[string]*testManifest
+	requests  []string
+}
+
+// newTestRegistry starts a fake registry serving the manifests, they are
+// available by reference and by digest.
+func newTestRegistry(manifests map[string]*testManifest) *testRegistry {
+	r := &testRegistry{
+		manifests: map[string]*testManifest{},
+	}
+
+	for ref, manifest := range manifests {
+		r.manifests[ref] = manifest
+		r.manifests[manifest.digest()] = manifest
+	}
+
+	r.server = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// client returns a distribution client for the fake registry.
+func (r *testRegistry) client(username, password string) *Client {
+	return NewClient(r.server.URL, username, password)
+}
+
+// handle implements the token endpoint and the manifest endpoints.
+func (r *testRegistry) handle(w http.ResponseWriter, req *http.Request) {
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+
+	if req.URL.Path == "/token" {
+		username, password, _ := req.BasicAuth()
+
+		if username != r.username || password != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if req.URL.Query().Get("service") != "registry.test" || req.URL.Query().Get("scope") != "repository:library/alpine:pull" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+		return
+	}
+
+	if !r.authorized(req) {
+		w.Header().Set("WWW-Authenticate", r.challenge())
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	reference := strings.TrimPrefix(req.URL.Path, "/v2/library/alpine/manifests/")
+	manifest, ok := r.manifests[reference]
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`)
+		return
+	}
+
+	w.Header().Set("Content-Type", manifest.mediaType)
+
+	if !r.noDigest {
+		w.Header().Set("Docker-Content-Digest", manifest.digest())
+	}
+
+	if req.Method == "HEAD" {
+		return
+	}
+
+	w.Write(manifest.body)
+}
+
+// authorized checks the authorization header against the configured auth.
+func (r *testRegistry) authorized(req *http.Request) bool {
+	switch r.auth {
+	case "basic":
+		username, password, ok := req.BasicAuth()
+		return ok && username == r.username && password == r.password
+	case "bearer":
+		return req.Header.Get("Authorization") == "Bearer "+r.token
+	}
+
+	return true
+}
+
+// challenge returns the authentication challenge for the configured auth.
+func (r *testRegistry) challenge() string {
+	if r.auth == "basic" {
+		return `Basic realm="registry"`
+	}
+
+	return fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, r.server.URL)
+}
+
+// count returns how often the request has been received.
+func (r *testRegistry) count(request string) int {
+	result := 0
+
+	for _, val := range r.requests {
+		if val == request {
+			result++
+		}
+	}
+
+	return result
+}
+
+// testImage builds an image manifest with the given layer sizes.
+func testImage(mediaType string, config int64, layers ...int64) *testManifest {
+	manifest := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaType,
+		Config: &Descriptor{
+			MediaType: "application/vnd.docker.container.image.v1+json",
+			Digest:    "sha256:config",
+			Size:      config,
+		},
+	}
+
+	for i, size := range layers {
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:    fmt.Sprintf("sha256:layer%d", i),
+			Size:      size,
+		})
+	}
+
+	body, _ := json.Marshal(manifest)
+	return &testManifest{mediaType: mediaType, body: body}
+}
+
+// testList builds a manifest list referencing the images.
+func testList(mediaType string, images map[string]*testManifest) *testManifest {
+	manifest := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaType,
+	}
+
+	for platform, image := range images {
+		parsed, _ := ParsePlatform(platform)
+
+		manifest.Manifests = append(manifest.Manifests, Descriptor{
+			MediaType: image.mediaType,
+			Digest:    image.digest(),
+			Size:      int64(len(image.body)),
+			Platform:  parsed,
+		})
+	}
+
+	body, _ := json.Marshal(manifest)
+	return &testManifest{mediaType: mediaType, body: body}
+}
+
+func TestManifest(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+	}{
+		{name: "schema2", mediaType: MediaTypeManifest},
+		{name: "oci", mediaType: MediaTypeOCIManifest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := testImage(tt.mediaType, 100, 1000, 2000)
+			registry := newTestRegistry(map[string]*testManifest{"latest": image})
+			defer registry.server.Close()
+
+			manifest, err := registry.client("", "").Manifest("library/alpine", "latest")
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if manifest.MediaType != tt.mediaType {
+				t.Errorf("expected media type %s, got %s", tt.mediaType, manifest.MediaType)
+			}
+
+			if manifest.IsList() {
+				t.Errorf("expected a single manifest")
+			}
+
+			if manifest.Digest != image.digest() {
+				t.Errorf("expected digest %s, got %s", image.digest(), manifest.Digest)
+			}
+
+			if len(manifest.Layers) != 2 {
+				t.Errorf("expected 2 layers, got %d", len(manifest.Layers))
+			}
+
+			if expected := int64(len(image.body)) + 3100; manifest.TotalSize() != expected {
+				t.Errorf("expected size %d, got %d", expected, manifest.TotalSize())
+			}
+		})
+	}
+}
+
+func TestManifestList(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		image     string
+	}{
+		{name: "docker", mediaType: MediaTypeManifestList, image: MediaTypeManifest},
+		{name: "oci", mediaType: MediaTypeOCIIndex, image: MediaTypeOCIManifest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amd64 := testImage(tt.image, 100, 1000)
+			arm64 := testImage(tt.image, 200, 2000, 3000)
+			list := testList(tt.mediaType, map[string]*testManifest{
+				"linux/amd64": amd64,
+				"linux/arm64": arm64,
+			})
+
+			registry := newTestRegistry(map[string]*testManifest{
+				"latest": list,
+				"amd64":  amd64,
+				"arm64":  arm64,
+			})
+
+			defer registry.server.Close()
+
+			client := registry.client("", "")
+			manifest, err := client.Manifest("library/alpine", "latest")
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !manifest.IsList() {
+				t.Fatalf("expected a manifest list, got %s", manifest.MediaType)
+			}
+
+			selected, err := manifest.Select(&Platform{OS: "linux", Architecture: "arm64"})
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if selected.Digest != arm64.digest() {
+				t.Errorf("expected digest %s, got %s", arm64.digest(), selected.Digest)
+			}
+
+			size, err := client.Size("library/alpine", manifest)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			expected := int64(len(list.body)+len(amd64.body)+len(arm64.body)) + 100 + 1000 + 200 + 2000 + 3000
+
+			if size != expected {
+				t.Errorf("expected size %d, got %d", expected, size)
+			}
+		})
+	}
+}
+
+func TestManifestUnknown(t *testing.T) {
+	registry := newTestRegistry(map[string]*testManifest{})
+	defer registry.server.Close()
+
+	_, err := registry.client("", "").Manifest("library/alpine", "latest")
+
+	regErr, ok := err.(*Error)
+
+	if !ok {
+		t.Fatalf("expected a registry error, got %v", err)
+	}
+
+	if regErr.StatusCode != http.StatusNotFound || regErr.Code != "MANIFEST_UNKNOWN" {
+		t.Errorf("expected manifest unknown, got %d %s", regErr.StatusCode, regErr.Code)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	image := testImage(MediaTypeManifest, 100, 1000)
+	registry := newTestRegistry(map[string]*testManifest{"latest": image})
+	defer registry.server.Close()
+
+	registry.auth = "bearer"
+	registry.username = "admin"
+	registry.password = "secret"
+	registry.token = "registry-token"
+
+	client := registry.client("admin", "secret")
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Manifest("library/alpine", "latest"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if count := registry.count("GET /token"); count != 1 {
+		t.Errorf("expected the token to be fetched once, got %d", count)
+	}
+
+	if count := registry.count("GET /v2/library/alpine/manifests/latest"); count != 3 {
+		t.Errorf("expected 3 manifest requests, got %d", count)
+	}
+
+	if _, err := registry.client("admin", "wrong").Manifest("library/alpine", "latest"); err == nil {
+		t.Errorf("expected an error for invalid credentials")
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	image := testImage(MediaTypeManifest, 100, 1000)
+	registry := newTestRegistry(map[string]*testManifest{"latest": image})
+	defer registry.server.Close()
+
+	registry.auth = "basic"
+	registry.username = "admin"
+	registry.password = "secret"
+
+	if _, err := registry.client("admin", "secret").Manifest("library/alpine", "latest"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := registry.client("", "").Manifest("library/alpine", "latest"); err == nil || !strings.Contains(err.Error(), "credentials required") {
+		t.Errorf("expected credentials required, got %v", err)
+	}
+
+	_, err := registry.client("admin", "wrong").Manifest("library/alpine", "latest")
+
+	if regErr, ok := err.(*Error); !ok || regErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized, got %v", err)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	image := testImage(MediaTypeManifest, 100, 1000)
+	registry := newTestRegistry(map[string]*testManifest{"latest": image})
+	defer registry.server.Close()
+
+	digest, err := registry.client("", "").Digest("library/alpine", "latest")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if digest != image.digest() {
+		t.Errorf("expected digest %s, got %s", image.digest(), digest)
+	}
+
+	if count := registry.count("GET /v2/library/alpine/manifests/latest"); count != 0 {
+		t.Errorf("expected no manifest download, got %d", count)
+	}
+}
+
+func TestDigestFallback(t *testing.T) {
+	image := testImage(MediaTypeManifest, 100, 1000)
+	registry := newTestRegistry(map[string]*testManifest{"latest": image})
+	defer registry.server.Close()
+
+	registry.noDigest = true
+
+	digest, err := registry.client("", "").Digest("library/alpine", "latest")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if digest != image.digest() {
+		t.Errorf("expected digest %s, got %s", image.digest(), digest)
+	}
+
+	if count := registry.count("HEAD /v2/library/alpine/manifests/latest"); count != 1 {
+		t.Errorf("expected a single HEAD request, got %d", count)
+	}
+
+	if count := registry.count("GET /v2/library/alpine/manifests/latest"); count != 1 {
+		t.Errorf("expected a manifest download, got %d", count)
+	}
+}
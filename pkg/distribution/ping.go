@@ -0,0 +1,104 @@
+package distribution
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PingResult represents the response of the API version check endpoint.
+type PingResult struct {
+	URL          string
+	StatusCode   int
+	Latency      time.Duration
+	APIVersion   string
+	AuthScheme   string
+	AuthRealm    string
+	Certificates []*x509.Certificate
+}
+
+// Ping requests the API version check endpoint without credentials to detect
+// the authentication scheme. The certificates are collected even if their
+// verification fails, that way expired certificates can be reported.
+func (c *Client) Ping() (*PingResult, error) {
+	base, err := c.baseURL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PingResult{
+		URL: base + "/v2/",
+	}
+
+	start := time.Now()
+	resp, err := c.HTTPClient.Get(result.URL)
+	result.Latency = time.Since(start)
+
+	if err != nil {
+		if strings.HasPrefix(base, "https://") {
+			result.Certificates = peerCertificates(base, c.HTTPClient.Timeout)
+		}
+
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.APIVersion = resp.Header.Get("Docker-Distribution-Api-Version")
+
+	if resp.TLS != nil {
+		result.Certificates = resp.TLS.PeerCertificates
+	}
+
+	if challenge := resp.Header.Get("WWW-Authenticate"); challenge != "" {
+		parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+		result.AuthScheme = strings.ToLower(parts[0])
+
+		if len(parts) == 2 {
+			for _, match := range challengePattern.FindAllStringSubmatch(parts[1], -1) {
+				if strings.ToLower(match[1]) == "realm" {
+					result.AuthRealm = match[2]
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// peerCertificates fetches the certificate chain without verification.
+func peerCertificates(base string, timeout time.Duration) []*x509.Certificate {
+	u, err := url.Parse(base)
+
+	if err != nil {
+		return nil
+	}
+
+	host := u.Host
+
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	conn, err := tls.DialWithDialer(
+		&net.Dialer{Timeout: timeout},
+		"tcp",
+		host,
+		&tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: true,
+		},
+	)
+
+	if err != nil {
+		return nil
+	}
+
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates
+}
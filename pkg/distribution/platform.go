@@ -0,0 +1,124 @@
+package distribution
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// defaultVariants defines the variant assumed if a platform omits it.
+var defaultVariants = map[string]string{
+	"arm64": "v8",
+	"arm":   "v7",
+}
+
+// ParsePlatform parses a platform in the os/arch[/variant] format.
+func ParsePlatform(val string) (*Platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(val)), "/")
+
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid platform %s, expected os/arch[/variant]", val)
+	}
+
+	result := &Platform{
+		OS:           parts[0],
+		Architecture: parts[1],
+	}
+
+	if len(parts) == 3 {
+		result.Variant = parts[2]
+	}
+
+	return result, nil
+}
+
+// Match checks if the platform satisfies the selector, the variant is only
+// compared if the selector defines one.
+func (p *Platform) Match(selector *Platform) bool {
+	if p.OS != selector.OS || p.Architecture != selector.Architecture {
+		return false
+	}
+
+	if selector.Variant == "" {
+		return true
+	}
+
+	return p.variant() == selector.variant()
+}
+
+// variant returns the variant including the architecture default.
+func (p *Platform) variant() string {
+	if p.Variant != "" {
+		return p.Variant
+	}
+
+	return defaultVariants[p.Architecture]
+}
+
+// Select returns the manifest list entry matching the selector, entries with
+// the default variant are preferred if the selector omits the variant.
+func (m *Manifest) Select(selector *Platform) (*Descriptor, error) {
+	var result *Descriptor
+
+	for i, child := range m.Manifests {
+		if child.Platform == nil || !child.Platform.Match(selector) {
+			continue
+		}
+
+		if result == nil || child.Platform.variant() == defaultVariants[child.Platform.Architecture] {
+			result = &m.Manifests[i]
+		}
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("no manifest available for platform %s", selector)
+	}
+
+	return result, nil
+}
+
+// ImageConfig represents the parts of the image config used by this client.
+type ImageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	OSVersion    string `json:"os.version,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Platform returns the platform defined by the image config.
+func (c *ImageConfig) Platform() *Platform {
+	return &Platform{
+		Architecture: c.Architecture,
+		OS:           c.OS,
+		OSVersion:    c.OSVersion,
+		Variant:      c.Variant,
+	}
+}
+
+// Config fetches the image config blob referenced by the manifest.
+func (c *Client) Config(repo string, manifest *Manifest) (*ImageConfig, error) {
+	if manifest.Config == nil {
+		return nil, fmt.Errorf("manifest %s has no config", manifest.Digest)
+	}
+
+	resp, err := c.Do(
+		"GET",
+		fmt.Sprintf("/v2/%s/blobs/%s", repo, manifest.Config.Digest),
+		pullScope(repo),
+		nil,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	result := &ImageConfig{}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %s", err)
+	}
+
+	return result, nil
+}
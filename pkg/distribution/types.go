@@ -0,0 +1,110 @@
+package distribution
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MediaTypeManifest defines the Docker image manifest schema 2.
+	MediaTypeManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// MediaTypeManifestList defines the Docker manifest list.
+	MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// MediaTypeOCIManifest defines the OCI image manifest.
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+
+	// MediaTypeOCIIndex defines the OCI image index.
+	MediaTypeOCIIndex = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestTypes defines the media types accepted for manifests.
+var manifestTypes = []string{
+	MediaTypeManifest,
+	MediaTypeManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeOCIIndex,
+}
+
+// Platform represents the platform of an image within a manifest list.
+type Platform struct {
+	Architecture string `json:"architecture" xml:"architecture"`
+	OS           string `json:"os" xml:"os"`
+	OSVersion    string `json:"os.version,omitempty" xml:"os-version,omitempty"`
+	Variant      string `json:"variant,omitempty" xml:"variant,omitempty"`
+}
+
+// String formats the platform as os/arch/variant.
+func (p *Platform) String() string {
+	parts := []string{p.OS, p.Architecture}
+
+	if p.Variant != "" {
+		parts = append(parts, p.Variant)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// Descriptor represents a reference to a blob or manifest.
+type Descriptor struct {
+	MediaType string    `json:"mediaType" xml:"media-type"`
+	Digest    string    `json:"digest" xml:"digest"`
+	Size      int64     `json:"size" xml:"size"`
+	Platform  *Platform `json:"platform,omitempty" xml:"platform,omitempty"`
+}
+
+// Manifest represents an image manifest or a manifest list, the digest and
+// size are taken from the response as they are not part of the payload.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        *Descriptor  `json:"config,omitempty"`
+	Layers        []Descriptor `json:"layers,omitempty"`
+	Manifests     []Descriptor `json:"manifests,omitempty"`
+	Digest        string       `json:"-"`
+	Size          int64        `json:"-"`
+}
+
+// IsList checks if the manifest is a manifest list or an image index.
+func (m *Manifest) IsList() bool {
+	return m.MediaType == MediaTypeManifestList || m.MediaType == MediaTypeOCIIndex
+}
+
+// TotalSize sums up the size of the manifest and all referenced blobs. The
+// layers of a manifest list are not part of the payload, so only the child
+// manifests are included, Client.Size resolves them.
+func (m *Manifest) TotalSize() int64 {
+	size := m.Size
+
+	if m.Config != nil {
+		size += m.Config.Size
+	}
+
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+
+	for _, manifest := range m.Manifests {
+		size += manifest.Size
+	}
+
+	return size
+}
+
+// Error represents an error response of the registry.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("registry returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("registry returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}